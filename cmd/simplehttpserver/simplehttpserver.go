@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/simplehttpserver/internal/runner"
 )
@@ -13,8 +18,23 @@ func main() {
 		gologger.Fatal().Msgf("Could not create runner: %s\n", err)
 	}
 
+	// Drain in-flight requests and connections on SIGINT/SIGTERM
+	shutdown := make(chan error, 1)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		gologger.Info().Msgf("Received %s, shutting down\n", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), options.ShutdownTimeout)
+		defer cancel()
+		shutdown <- r.Shutdown(ctx)
+	}()
+
 	if err := r.Run(); err != nil {
-		gologger.Info().Msgf("%s\n", err)
+		r.Close() //nolint
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+	if err := <-shutdown; err != nil {
+		gologger.Fatal().Msgf("Could not shutdown gracefully: %s\n", err)
 	}
-	defer r.Close() //nolint
 }
@@ -1,12 +1,17 @@
 module github.com/projectdiscovery/simplehttpserver
 
-go 1.19
+go 1.24
 
 require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/andybalholm/brotli v1.0.5
 	github.com/fsnotify/fsnotify v1.6.0
-	github.com/phayes/freeport v0.0.0-20180830031419-95f893ade6f2
+	github.com/klauspost/compress v1.16.7
 	github.com/projectdiscovery/gologger v1.1.8
 	github.com/projectdiscovery/sslcert v0.0.0-20210416140253-8f56bec1bb5e
+	github.com/quic-go/quic-go v0.54.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/net v0.28.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
@@ -20,8 +25,15 @@ require (
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/nwaples/rardecode v1.1.0 // indirect
 	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
+	github.com/quic-go/qpack v0.5.1 // indirect
 	github.com/ulikunitz/xz v0.5.7 // indirect
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
-	golang.org/x/sys v0.0.0-20220908164124-27713097b956 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
 	gopkg.in/djherbis/times.v1 v1.3.0 // indirect
 )
@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// envPrefix of the environment variables overriding the options
+const envPrefix = "SHS_"
+
+// flags which only make sense on the command line
+var metaFlags = map[string]struct{}{
+	"config":      {},
+	"dump-config": {},
+	"version":     {},
+}
+
+// listValue is implemented by the options which can be specified multiple times
+type listValue interface {
+	Values() []string
+}
+
+// envName returns the environment variable overriding the flag (eg. SHS_MAX_FILE_SIZE)
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// readConfigFile reads a yaml or toml file (detected by extension) with the flag names as keys
+func readConfigFile(configPath string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".toml":
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("%s: %s", configPath, err)
+		}
+	default:
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("%s: %s", configPath, err)
+		}
+	}
+	return values, nil
+}
+
+// configValues converts a config value to the strings accepted by the flag
+func configValues(key string, value interface{}, isList bool) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		if !isList {
+			return nil, fmt.Errorf("key '%s' accepts a single value", key)
+		}
+		values := make([]string, 0, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case []interface{}, map[interface{}]interface{}, map[string]interface{}:
+				return nil, fmt.Errorf("key '%s[%d]' must be a scalar value", key, i)
+			}
+			values = append(values, fmt.Sprint(item))
+		}
+		return values, nil
+	case map[interface{}]interface{}, map[string]interface{}:
+		return nil, fmt.Errorf("key '%s' must be a value or a list of values", key)
+	case nil:
+		return nil, nil
+	default:
+		return []string{fmt.Sprint(v)}, nil
+	}
+}
+
+// loadConfig applies the config file and the environment variables to the flags which
+// were not set on the command line. The environment takes precedence over the file.
+func loadConfig(flagSet *flag.FlagSet, configPath string) error {
+	fromCLI := make(map[string]struct{})
+	flagSet.Visit(func(f *flag.Flag) {
+		fromCLI[f.Name] = struct{}{}
+	})
+
+	sources := make(map[string][]string)
+	sourceNames := make(map[string]string)
+	if configPath != "" {
+		fileValues, err := readConfigFile(configPath)
+		if err != nil {
+			return err
+		}
+		for key, value := range fileValues {
+			f := flagSet.Lookup(key)
+			if _, meta := metaFlags[key]; f == nil || meta {
+				return fmt.Errorf("%s: unknown key '%s'", configPath, key)
+			}
+			_, isList := f.Value.(listValue)
+			values, err := configValues(key, value, isList)
+			if err != nil {
+				return fmt.Errorf("%s: %s", configPath, err)
+			}
+			sources[key] = values
+			sourceNames[key] = fmt.Sprintf("%s: key '%s'", configPath, key)
+		}
+	}
+
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if _, meta := metaFlags[f.Name]; meta {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			// repeatable options accept one value per line
+			if _, isList := f.Value.(listValue); isList {
+				sources[f.Name] = strings.Split(strings.TrimSpace(value), "\n")
+			} else {
+				sources[f.Name] = []string{value}
+			}
+			sourceNames[f.Name] = "environment variable " + name
+		}
+	})
+
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := fromCLI[key]; ok {
+			continue
+		}
+		for _, value := range sources[key] {
+			if err := flagSet.Set(key, strings.TrimSpace(value)); err != nil {
+				return fmt.Errorf("%s: invalid value '%s': %s", sourceNames[key], value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// redactedValue replaces the secrets in the dumped configuration, as in the urls redacted by net/url
+const redactedValue = "xxxxx"
+
+// secretHeaders carry credentials in the response and upstream request headers
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// redactSecret hides the password of the basic auth and of the proxy upstreams, and the credential headers
+func redactSecret(name, value string) string {
+	switch name {
+	case "basic-auth":
+		if user, _, ok := strings.Cut(value, ":"); ok {
+			return user + ":" + redactedValue
+		}
+	case "header", "proxy-header":
+		header, _, _ := strings.Cut(value, ":")
+		if slices.ContainsFunc(secretHeaders, func(secret string) bool { return strings.EqualFold(strings.TrimSpace(header), secret) }) {
+			return header + ": " + redactedValue
+		}
+	case "proxy":
+		if prefix, upstream, ok := strings.Cut(value, "="); ok {
+			if u, err := url.Parse(upstream); err == nil {
+				return prefix + "=" + u.Redacted()
+			}
+		}
+	}
+	return value
+}
+
+// dumpConfig prints the effective configuration in yaml format, with the secrets redacted
+func dumpConfig(flagSet *flag.FlagSet) (string, error) {
+	values := make(map[string]interface{})
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if _, meta := metaFlags[f.Name]; meta {
+			return
+		}
+		switch v := f.Value.(type) {
+		case listValue:
+			list := v.Values()
+			for i := range list {
+				list[i] = redactSecret(f.Name, list[i])
+			}
+			values[f.Name] = list
+		case flag.Getter:
+			value := v.Get()
+			switch typed := value.(type) {
+			case time.Duration:
+				value = typed.String()
+			case string:
+				value = redactSecret(f.Name, typed)
+			}
+			values[f.Name] = value
+		default:
+			values[f.Name] = redactSecret(f.Name, f.Value.String())
+		}
+	})
+	data, err := yaml.Marshal(values)
+	return string(data), err
+}
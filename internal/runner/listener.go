@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+const defaultListenAddress = "0.0.0.0:8000"
+
+// Supported listener protocols
+const (
+	ProtocolHTTP  = "http"
+	ProtocolHTTPS = "https"
+	ProtocolTCP   = "tcp"
+	ProtocolTLS   = "tls"
+)
+
+// NetworkUnix is the network of the listeners on unix domain sockets (eg. unix:/run/shs.sock)
+const NetworkUnix = "unix"
+
+// Listener is an address served with a protocol
+type Listener struct {
+	Protocol string `json:"protocol"`
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address"`
+}
+
+func (l Listener) String() string {
+	address := l.Address
+	if l.Network == NetworkUnix {
+		address = NetworkUnix + ":" + address
+	}
+	if l.Protocol == "" {
+		return address
+	}
+	return l.Protocol + "://" + address
+}
+
+// ParseListener parses an address or a unix socket (unix:/path) optionally prefixed by the
+// protocol (eg. https://0.0.0.0:8443)
+func ParseListener(value string) (Listener, error) {
+	var listener Listener
+	if protocol, address, ok := strings.Cut(value, "://"); ok {
+		switch protocol {
+		case ProtocolHTTP, ProtocolHTTPS, ProtocolTCP, ProtocolTLS:
+		default:
+			return listener, fmt.Errorf("listener '%s' has an unsupported protocol '%s'", value, protocol)
+		}
+		listener.Protocol = protocol
+		value = address
+	}
+	if strings.HasPrefix(value, NetworkUnix+":") {
+		socketPath := strings.TrimPrefix(value, NetworkUnix+":")
+		if socketPath == "" {
+			return listener, fmt.Errorf("listener '%s' has an empty socket path", value)
+		}
+		listener.Network = NetworkUnix
+		listener.Address = socketPath
+		return listener, nil
+	}
+	if _, _, err := net.SplitHostPort(value); err != nil {
+		return listener, fmt.Errorf("listener '%s' not in format 'address:port': %s", value, err)
+	}
+	listener.Address = value
+	return listener, nil
+}
+
+// Listeners is a slice of Listener structs
+type Listeners []Listener
+
+func (l *Listeners) String() string {
+	return strings.Join(l.Values(), ",")
+}
+
+// Values returns the listeners in the form 'protocol://address:port'
+func (l *Listeners) Values() []string {
+	values := make([]string, 0, len(*l))
+	for _, listener := range *l {
+		values = append(values, listener.String())
+	}
+	return values
+}
+
+// Set adds a new listener
+func (l *Listeners) Set(value string) error {
+	listener, err := ParseListener(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, listener)
+	return nil
+}
+
+// First returns the first listener using one of the protocols
+func (l Listeners) First(protocols ...string) (Listener, bool) {
+	for _, listener := range l {
+		for _, protocol := range protocols {
+			if listener.Protocol == protocol {
+				return listener, true
+			}
+		}
+	}
+	return Listener{}, false
+}
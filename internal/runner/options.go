@@ -3,19 +3,28 @@ package runner
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/simplehttpserver/pkg/binder"
 	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
 )
 
 // Options of the tool
 type Options struct {
-	ListenAddress   string
+	Listeners       Listeners
 	Folder          string
+	Folders         Paths
+	UploadPath      string
+	Mounts          MountRules
 	BasicAuth       string
 	username        string
 	password        string
@@ -26,24 +35,73 @@ type Options struct {
 	HTTPS           bool
 	Verbose         bool
 	EnableUpload    bool
+	Mode            string
+	UploadConflict  string
 	EnableTCP       bool
 	RulesFile       string
 	TCPWithTLS      bool
 	Version         bool
 	Silent          bool
 	Sandbox         bool
+	Include         string
+	Exclude         string
+	ExcludeFile     string
+	includes        []string
+	excludes        []string
 	MaxFileSize     int
 	HTTP1Only       bool
+	HTTP3           bool
+	H2C             bool
 	MaxDumpBodySize int
+	MaxArchiveSize  int
 	Python          bool
+	ListingTemplate string
 	CORS            bool
 	HTTPHeaders     HTTPHeaders
+	Proxies         ProxyRules
+	ProxyStrip      bool
+	ProxyKeepHost   bool
+	ProxyHeaders    HTTPHeaders
+	SPA             bool
+	SPAIndex        string
+	Compress        bool
+	CompressMinSize int
+	CompressTypes   string
+	Precompressed   bool
+	CacheRules      CacheRules
+	CacheConfig     string
+	ETag            bool
+	BandwidthConn   int
+	BandwidthGlobal int
+	Latency         time.Duration
+	DropRate        float64
+	ResetRate       float64
+	StallRate       float64
+	StallDuration   time.Duration
+	RateLimit       float64
+	RateBurst       int
+	MaxConns        int
+	MaxConnsPerIP   int
+	ShutdownTimeout time.Duration
+	SignedLinkTTL   time.Duration
+	QRCode          bool
+	QRInterface     string
+	StrictPort      bool
+	PortRange       string
+	portRangeStart  int
+	portRangeEnd    int
+	AddressFile     string
+	SocketMode      string
+	socketMode      os.FileMode
+	SocketActivate  bool
+	ConfigFile      string
+	DumpConfig      bool
 }
 
 // ParseOptions parses the command line options for application
 func ParseOptions() *Options {
 	options := &Options{}
-	flag.StringVar(&options.ListenAddress, "listen", "0.0.0.0:8000", "Address:Port")
+	flag.Var(&options.Listeners, "listen", "Address:Port, optionally prefixed by http://, https://, tcp:// or tls:// (default 0.0.0.0:8000), can be used multiple times")
 	flag.BoolVar(&options.EnableTCP, "tcp", false, "TCP Server")
 	flag.BoolVar(&options.TCPWithTLS, "tls", false, "Enable TCP TLS")
 	flag.StringVar(&options.RulesFile, "rules", "", "Rules yaml file")
@@ -51,8 +109,13 @@ func ParseOptions() *Options {
 	if p, err := os.Getwd(); err == nil {
 		currentPath = p
 	}
-	flag.StringVar(&options.Folder, "path", currentPath, "Folder")
+	options.Folder = currentPath
+	flag.Var(&options.Folders, "path", "Folder, or zip/tar archive served read-only (default current directory), can be used multiple times to overlay folders (the first ones shadow the others)")
 	flag.BoolVar(&options.EnableUpload, "upload", false, "Enable upload via PUT")
+	flag.StringVar(&options.Mode, "mode", "", "Access mode enforcing the allowed methods: read, write, readwrite or dropbox (upload only, without overwrites)")
+	flag.Var(&options.Mounts, "mount", "Serve a folder or archive under a prefix (/prefix=/dir[:ro|rw][:sandbox]), can be used multiple times")
+	flag.StringVar(&options.UploadConflict, "upload-conflict", "", "Policy for the uploads of existing files: overwrite (default), reject (409, default in dropbox mode), rename or version (previous versions kept in .shs-versions)")
+	flag.StringVar(&options.UploadPath, "upload-path", "", "Overlaid path receiving the uploads (default first path)")
 	flag.BoolVar(&options.HTTPS, "https", false, "HTTPS")
 	flag.StringVar(&options.TLSCertificate, "cert", "", "HTTPS Certificate")
 	flag.StringVar(&options.TLSKey, "key", "", "HTTPS Certificate Key")
@@ -63,14 +126,61 @@ func ParseOptions() *Options {
 	flag.BoolVar(&options.Version, "version", false, "Show version of the software")
 	flag.BoolVar(&options.Silent, "silent", false, "Show only results in the output")
 	flag.BoolVar(&options.Sandbox, "sandbox", false, "Enable sandbox mode")
+	flag.StringVar(&options.Exclude, "exclude", "", "Comma separated .gitignore-style patterns hidden from listings, downloads and uploads (eg. *.key,node_modules,.git)")
+	flag.StringVar(&options.Include, "include", "", "Comma separated patterns served even if excluded, or dotfiles in sandbox mode (eg. .well-known)")
+	flag.StringVar(&options.ExcludeFile, "exclude-file", "", ".gitignore-style file with the excluded patterns (! for the included ones)")
 	flag.BoolVar(&options.HTTP1Only, "http1", false, "Enable only HTTP1")
+	flag.BoolVar(&options.HTTP3, "http3", false, "Serve the https listeners over http/3 (quic) on the same udp port")
+	flag.BoolVar(&options.H2C, "h2c", false, "Serve http/2 over cleartext (prior knowledge and upgrade) on the http listeners")
 	flag.IntVar(&options.MaxFileSize, "max-file-size", 50, "Max Upload File Size")
 	flag.IntVar(&options.MaxDumpBodySize, "max-dump-body-size", -1, "Max Dump Body Size")
+	flag.IntVar(&options.MaxArchiveSize, "max-archive-size", 0, "Max size in Mb of the folders downloaded as zip or tar.gz (0 for unlimited)")
 	flag.BoolVar(&options.Python, "py", false, "Emulate Python Style")
+	flag.StringVar(&options.ListingTemplate, "listing-template", "", "Directory listing template: python, rich or an html/template file")
 	flag.BoolVar(&options.CORS, "cors", false, "Enable Cross-Origin Resource Sharing (CORS)")
 	flag.Var(&options.HTTPHeaders, "header", "Add HTTP Response Header (name: value), can be used multiple times")
+	flag.Var(&options.Proxies, "proxy", "Reverse proxy path prefix to upstream (/prefix=http://host:port), can be used multiple times")
+	flag.BoolVar(&options.ProxyStrip, "proxy-strip-prefix", false, "Strip the matched prefix before forwarding to the upstream")
+	flag.BoolVar(&options.ProxyKeepHost, "proxy-preserve-host", false, "Forward the original Host header to the upstream")
+	flag.Var(&options.ProxyHeaders, "proxy-header", "Set HTTP Request Header sent to the upstream (name: value, empty value removes it), can be used multiple times")
+	flag.BoolVar(&options.SPA, "spa", false, "Serve the index file for unknown paths (single-page application)")
+	flag.StringVar(&options.SPAIndex, "spa-index", "index.html", "Index file served in single-page application mode")
+	flag.BoolVar(&options.Compress, "compress", false, "Compress responses with gzip, brotli or zstd")
+	flag.IntVar(&options.CompressMinSize, "compress-min-size", 1024, "Min response size in bytes to compress")
+	flag.StringVar(&options.CompressTypes, "compress-types", strings.Join(httpserver.DefaultCompressTypes, ","), "Comma separated content types to compress (prefixes ending with /)")
+	flag.BoolVar(&options.Precompressed, "precompressed", false, "Serve precompressed .br, .zst and .gz siblings when present")
+	flag.Var(&options.CacheRules, "cache-control", "Cache-Control header for files matching a glob (glob=value), can be used multiple times")
+	flag.StringVar(&options.CacheConfig, "cache-config", "", "Cache-Control rules yaml file")
+	flag.BoolVar(&options.ETag, "etag", false, "Add content hash ETags to served files")
+	flag.IntVar(&options.BandwidthConn, "bandwidth-conn", 0, "Max bandwidth per connection in KB/s")
+	flag.IntVar(&options.BandwidthGlobal, "bandwidth-global", 0, "Max bandwidth shared by all connections in KB/s")
+	flag.DurationVar(&options.Latency, "latency", 0, "Latency injected before the first byte of each response (eg. 200ms)")
+	flag.Float64Var(&options.DropRate, "drop-rate", 0, "Probability (0-1) to close the connection without answering")
+	flag.Float64Var(&options.ResetRate, "reset-rate", 0, "Probability (0-1) to reset the connection without answering")
+	flag.Float64Var(&options.StallRate, "stall-rate", 0, "Probability (0-1) to stall in the middle of a response")
+	flag.DurationVar(&options.StallDuration, "stall-duration", 10*time.Second, "Duration of a stall")
+	flag.Float64Var(&options.RateLimit, "rate-limit", 0, "Max requests per second per client (ip or authenticated user)")
+	flag.IntVar(&options.RateBurst, "rate-burst", 0, "Max burst of requests per client (default rate-limit)")
+	flag.IntVar(&options.MaxConns, "max-conns", 0, "Max concurrent connections")
+	flag.IntVar(&options.MaxConnsPerIP, "max-conns-per-ip", 0, "Max concurrent connections per ip")
+	flag.DurationVar(&options.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "Max time to drain in-flight requests on shutdown")
+	flag.DurationVar(&options.SignedLinkTTL, "signed-link-ttl", 0, "Print links signed with a token granting access without credentials for the duration (eg. 1h)")
+	flag.BoolVar(&options.QRCode, "qr", false, "Print a qr code of the url at startup")
+	flag.StringVar(&options.QRInterface, "qr-interface", "", "Network interface used for the qr code url (default first non loopback)")
+	flag.BoolVar(&options.StrictPort, "strict-port", false, "Fail instead of picking a random port when the port is in use")
+	flag.StringVar(&options.PortRange, "port-range", "", "Listen on the first free port of the range (eg. 8000-8100)")
+	flag.StringVar(&options.AddressFile, "address-file", "", "Write the bound addresses as json to the file ('-' for stdout)")
+	flag.StringVar(&options.SocketMode, "socket-mode", "0660", "Permissions of the unix socket listeners")
+	flag.BoolVar(&options.SocketActivate, "socket-activation", false, "Serve the listeners passed by systemd socket activation (LISTEN_FDS)")
+	flag.StringVar(&options.ConfigFile, "config", "", "Yaml or toml configuration file using the flag names as keys")
+	flag.BoolVar(&options.DumpConfig, "dump-config", false, "Print the effective configuration, with the secrets redacted, and exit")
 	flag.Parse()
 
+	// Command line flags override the environment which overrides the config file
+	if err := loadConfig(flag.CommandLine, options.ConfigFile); err != nil {
+		gologger.Fatal().Msgf("Could not load configuration: %s\n", err)
+	}
+
 	// Read the inputs and configure the logging
 	options.configureOutput()
 
@@ -83,6 +193,15 @@ func ParseOptions() *Options {
 
 	options.validateOptions()
 
+	if options.DumpConfig {
+		config, err := dumpConfig(flag.CommandLine)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not dump configuration: %s\n", err)
+		}
+		fmt.Print(config)
+		os.Exit(0)
+	}
+
 	return options
 }
 
@@ -90,6 +209,78 @@ func (options *Options) validateOptions() {
 	if flag.NArg() > 0 && options.Folder == "." {
 		options.Folder = flag.Args()[0]
 	}
+	// with mounts the folder is only served if explicitly requested
+	if len(options.Folders) == 0 && len(options.Mounts) == 0 {
+		options.Folders = Paths{options.Folder}
+	}
+	options.Folder = ""
+	if len(options.Folders) > 0 {
+		options.Folder = options.Folders[0]
+	}
+
+	for name, probability := range map[string]float64{"drop-rate": options.DropRate, "reset-rate": options.ResetRate, "stall-rate": options.StallRate} {
+		if probability < 0 || probability > 1 {
+			gologger.Fatal().Msgf("%s must be between 0 and 1\n", name)
+		}
+	}
+
+	// with socket activation the listeners are inherited from the service manager
+	if len(options.Listeners) == 0 && !options.SocketActivate {
+		options.Listeners = Listeners{{Address: defaultListenAddress}}
+	}
+	// listeners without an explicit protocol follow the -tcp, -tls and -https flags
+	for i := range options.Listeners {
+		if options.Listeners[i].Protocol == "" {
+			options.Listeners[i].Protocol = options.defaultProtocol()
+		}
+	}
+	socketMode, err := strconv.ParseUint(options.SocketMode, 8, 32)
+	if err != nil || socketMode > 0777 {
+		gologger.Fatal().Msgf("invalid socket-mode '%s'\n", options.SocketMode)
+	}
+	options.socketMode = os.FileMode(socketMode)
+
+	if options.PortRange != "" {
+		if options.StrictPort {
+			gologger.Fatal().Msgf("strict-port and port-range are mutually exclusive\n")
+		}
+		start, end, err := binder.ParsePortRange(options.PortRange)
+		if err != nil {
+			gologger.Fatal().Msgf("%s\n", err)
+		}
+		options.portRangeStart, options.portRangeEnd = start, end
+	}
+	if options.Mode != "" && !slices.Contains(httpserver.Modes, options.Mode) {
+		gologger.Fatal().Msgf("invalid mode '%s', must be one of %s\n", options.Mode, strings.Join(httpserver.Modes, ", "))
+	}
+	if options.UploadConflict != "" && !slices.Contains(httpserver.ConflictPolicies, options.UploadConflict) {
+		gologger.Fatal().Msgf("invalid upload-conflict '%s', must be one of %s\n", options.UploadConflict, strings.Join(httpserver.ConflictPolicies, ", "))
+	}
+	if err := httpserver.ValidateMode(options.Mode, options.UploadConflict); err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+	options.includes, options.excludes = splitList(options.Include), splitList(options.Exclude)
+	if options.ExcludeFile != "" {
+		include, exclude, err := httpserver.LoadIgnoreFile(options.ExcludeFile)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not read exclude file: %s\n", err)
+		}
+		options.includes = append(options.includes, include...)
+		options.excludes = append(options.excludes, exclude...)
+	}
+	if options.H2C && options.HTTP1Only {
+		gologger.Fatal().Msgf("h2c and http1 are mutually exclusive\n")
+	}
+	if _, ok := options.Listeners.First(ProtocolHTTPS); options.HTTP3 && !ok && !options.SocketActivate {
+		gologger.Fatal().Msgf("http3 requires an https listener (-https)\n")
+	}
+	if _, ok := options.Listeners.First(ProtocolTCP, ProtocolTLS); ok && options.RulesFile == "" {
+		gologger.Fatal().Msgf("TCP listeners require a rules file (-rules)\n")
+	}
+	// the tokens are only checked in place of the basic auth credentials
+	if options.SignedLinkTTL > 0 && options.BasicAuth == "" {
+		gologger.Fatal().Msgf("signed-link-ttl requires basic auth (-basic-auth)\n")
+	}
 
 	if options.BasicAuth != "" {
 		baTokens := strings.SplitN(options.BasicAuth, ":", 2)
@@ -113,13 +304,64 @@ func (options *Options) configureOutput() {
 	}
 }
 
-// FolderAbsPath of the fileserver folder
+func (options *Options) defaultProtocol() string {
+	switch {
+	case options.EnableTCP && options.TCPWithTLS:
+		return ProtocolTLS
+	case options.EnableTCP:
+		return ProtocolTCP
+	case options.HTTPS:
+		return ProtocolHTTPS
+	default:
+		return ProtocolHTTP
+	}
+}
+
+// FolderAbsPath of the fileserver folders, the overlaid ones and the mounts are joined with '+'
 func (options *Options) FolderAbsPath() string {
-	abspath, err := filepath.Abs(options.Folder)
-	if err != nil {
-		return options.Folder
+	abspaths := make([]string, 0, len(options.Folders))
+	for _, folder := range options.Folders {
+		if abspath, err := filepath.Abs(folder); err == nil {
+			folder = abspath
+		}
+		abspaths = append(abspaths, folder)
+	}
+	for _, mount := range options.Mounts {
+		abspaths = append(abspaths, mount.String())
 	}
-	return abspath
+	return strings.Join(abspaths, " + ")
+}
+
+// Paths are the folders overlaid by the fileserver, the first ones shadowing the others
+type Paths []string
+
+func (p *Paths) String() string {
+	return strings.Join(*p, ",")
+}
+
+// Values returns the paths in order
+func (p *Paths) Values() []string {
+	return *p
+}
+
+// Set adds a path below the previous ones
+func (p *Paths) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty path")
+	}
+	*p = append(*p, value)
+	return nil
+}
+
+// splitList splits a comma separated list discarding empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // HTTPHeaders is a slice of HTTPHeader structs
@@ -129,6 +371,15 @@ func (h *HTTPHeaders) String() string {
 	return fmt.Sprint(*h)
 }
 
+// Values returns the headers in the form 'name: value'
+func (h *HTTPHeaders) Values() []string {
+	values := make([]string, 0, len(*h))
+	for _, header := range *h {
+		values = append(values, header.Name+":"+header.Value)
+	}
+	return values
+}
+
 // Set sets a new header, which must be a string of the form 'name: value'
 func (h *HTTPHeaders) Set(value string) error {
 	tokens := strings.SplitN(value, ":", 2)
@@ -139,3 +390,148 @@ func (h *HTTPHeaders) Set(value string) error {
 	*h = append(*h, httpserver.HTTPHeader{Name: tokens[0], Value: tokens[1]})
 	return nil
 }
+
+// ProxyRules is a slice of ProxyRule structs
+type ProxyRules []httpserver.ProxyRule
+
+func (p *ProxyRules) String() string {
+	return strings.Join(p.Values(), ",")
+}
+
+// Values returns the rules in the form '/prefix=http://host:port'
+func (p *ProxyRules) Values() []string {
+	values := make([]string, 0, len(*p))
+	for _, rule := range *p {
+		values = append(values, rule.Prefix+"="+rule.Upstream.String())
+	}
+	return values
+}
+
+// Set adds a new proxy rule, which must be a string of the form '/prefix=http://host:port'
+func (p *ProxyRules) Set(value string) error {
+	tokens := strings.SplitN(value, "=", 2)
+	if len(tokens) != 2 || !strings.HasPrefix(tokens[0], "/") {
+		return fmt.Errorf("proxy '%s' not in format '/prefix=http://host:port'", value)
+	}
+	upstream, err := url.Parse(tokens[1])
+	if err != nil {
+		return fmt.Errorf("proxy '%s' has an invalid upstream: %s", value, err)
+	}
+	if upstream.Scheme != "http" && upstream.Scheme != "https" || upstream.Host == "" {
+		return fmt.Errorf("proxy '%s' upstream must be an absolute http(s) url", value)
+	}
+
+	*p = append(*p, httpserver.ProxyRule{Prefix: tokens[0], Upstream: upstream})
+	return nil
+}
+
+// CacheRules is a slice of CacheRule structs
+type CacheRules []httpserver.CacheRule
+
+func (c *CacheRules) String() string {
+	return fmt.Sprint(*c)
+}
+
+// Values returns the rules in the form 'glob=value'
+func (c *CacheRules) Values() []string {
+	values := make([]string, 0, len(*c))
+	for _, rule := range *c {
+		values = append(values, rule.Match+"="+rule.Value)
+	}
+	return values
+}
+
+// Set adds a new cache rule, which must be a string of the form 'glob=value'
+func (c *CacheRules) Set(value string) error {
+	tokens := strings.SplitN(value, "=", 2)
+	if len(tokens) != 2 {
+		return fmt.Errorf("cache-control '%s' not in format 'glob=value'", value)
+	}
+	if _, err := path.Match(tokens[0], ""); err != nil {
+		return fmt.Errorf("cache-control '%s' has an invalid glob: %s", value, err)
+	}
+
+	*c = append(*c, httpserver.CacheRule{Match: tokens[0], Value: strings.TrimSpace(tokens[1])})
+	return nil
+}
+
+// MountRule serves a folder under a prefix, the access (ro or rw) defaults to -upload
+// and the sandbox to -sandbox
+type MountRule struct {
+	Prefix  string
+	Folder  string
+	Access  string
+	Sandbox bool
+}
+
+func (m MountRule) String() string {
+	value := m.Prefix + "=" + m.Folder
+	if m.Access != "" {
+		value += ":" + m.Access
+	}
+	if m.Sandbox {
+		value += ":sandbox"
+	}
+	return value
+}
+
+// MountRules is a slice of MountRule structs
+type MountRules []MountRule
+
+func (m *MountRules) String() string {
+	return strings.Join(m.Values(), ",")
+}
+
+// Values returns the mounts in the form '/prefix=/dir[:ro|rw][:sandbox]'
+func (m *MountRules) Values() []string {
+	values := make([]string, 0, len(*m))
+	for _, mount := range *m {
+		values = append(values, mount.String())
+	}
+	return values
+}
+
+// Set adds a new mount, which must be a string of the form '/prefix=/dir[:ro|rw][:sandbox]'
+func (m *MountRules) Set(value string) error {
+	tokens := strings.SplitN(value, "=", 2)
+	if len(tokens) != 2 || !strings.HasPrefix(tokens[0], "/") || path.Clean(tokens[0]) == "/" {
+		return fmt.Errorf("mount '%s' not in format '/prefix=/dir[:ro|rw][:sandbox]'", value)
+	}
+	mount := MountRule{Prefix: path.Clean(tokens[0]), Folder: tokens[1]}
+	// the options are read from the end as the folder can contain colons
+	for {
+		i := strings.LastIndex(mount.Folder, ":")
+		if i == -1 {
+			break
+		}
+		option := mount.Folder[i+1:]
+		if option == "ro" || option == "rw" {
+			mount.Access = option
+		} else if option == "sandbox" {
+			mount.Sandbox = true
+		} else {
+			break
+		}
+		mount.Folder = mount.Folder[:i]
+	}
+	if mount.Folder == "" {
+		return fmt.Errorf("mount '%s' has an empty folder", value)
+	}
+
+	*m = append(*m, mount)
+	return nil
+}
+
+// httpMounts returns the mounts with the default access and sandbox applied
+func (options *Options) httpMounts() []httpserver.Mount {
+	mounts := make([]httpserver.Mount, 0, len(options.Mounts))
+	for _, mount := range options.Mounts {
+		mounts = append(mounts, httpserver.Mount{
+			Prefix:  mount.Prefix,
+			Folder:  mount.Folder,
+			Upload:  mount.Access == "rw" || mount.Access == "" && httpserver.UploadEnabled(options.Mode, options.EnableUpload),
+			Sandbox: mount.Sandbox || options.Sandbox,
+		})
+	}
+	return mounts
+}
@@ -1,9 +1,22 @@
 package runner
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/simplehttpserver/pkg/binder"
 	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+	"github.com/projectdiscovery/simplehttpserver/pkg/ratelimit"
 	"github.com/projectdiscovery/simplehttpserver/pkg/tcpserver"
 	"github.com/projectdiscovery/simplehttpserver/pkg/unit"
 )
@@ -13,50 +26,120 @@ type Runner struct {
 	options    *Options
 	serverTCP  *tcpserver.TCPServer
 	httpServer *httpserver.HTTPServer
+	watcher    *fsnotify.Watcher
+	qrOnce     sync.Once
+	// bound sockets, in the same order as the listeners options
+	netListeners []net.Listener
+	// udp sockets of the https listeners served over http/3, by listener index
+	packetConns map[int]net.PacketConn
 }
 
 // New instance of runner
 func New(options *Options) (*Runner, error) {
 	r := Runner{options: options}
-	// Check if the process can listen on the specified ip:port
-	if !binder.CanListenOn(r.options.ListenAddress) {
-		newListenAddress, err := binder.GetRandomListenAddress(r.options.ListenAddress)
+	for i, listener := range r.options.Listeners {
+		netListener, err := r.listen(listener)
 		if err != nil {
+			r.Close() //nolint
 			return nil, err
 		}
-		gologger.Print().Msgf("Can't listen on %s: %s - Using %s\n", r.options.ListenAddress, err, newListenAddress)
-		r.options.ListenAddress = newListenAddress
+		r.netListeners = append(r.netListeners, netListener)
+		if listener.Network != NetworkUnix {
+			r.options.Listeners[i].Address = boundAddress(listener.Address, netListener)
+		}
 	}
+	if r.options.SocketActivate {
+		if err := r.inheritListeners(); err != nil {
+			r.Close() //nolint
+			return nil, err
+		}
+	}
+	if r.options.HTTP3 {
+		if err := r.listenHTTP3(); err != nil {
+			r.Close() //nolint
+			return nil, err
+		}
+	}
+
+	simulator := netsim.New(&netsim.Options{
+		ConnectionRate:   unit.KbToBytes(r.options.BandwidthConn),
+		GlobalRate:       unit.KbToBytes(r.options.BandwidthGlobal),
+		Latency:          r.options.Latency,
+		DropProbability:  r.options.DropRate,
+		ResetProbability: r.options.ResetRate,
+		StallProbability: r.options.StallRate,
+		StallDuration:    r.options.StallDuration,
+	})
 
-	if r.options.EnableTCP {
+	var connLimiter *ratelimit.ConnLimiter
+	if r.options.MaxConns > 0 || r.options.MaxConnsPerIP > 0 {
+		connLimiter = ratelimit.NewConnLimiter(r.options.MaxConns, r.options.MaxConnsPerIP)
+	}
+
+	if tcpListener, ok := r.options.Listeners.First(ProtocolTCP, ProtocolTLS); ok {
 		serverTCP, err := tcpserver.New(&tcpserver.Options{
-			Listen:  r.options.ListenAddress,
-			TLS:     r.options.TCPWithTLS,
-			Domain:  "local.host",
-			Verbose: r.options.Verbose,
+			Listen:      tcpListener.Address,
+			TLS:         tcpListener.Protocol == ProtocolTLS,
+			Certificate: r.options.TLSCertificate,
+			Key:         r.options.TLSKey,
+			Domain:      r.options.TLSDomain,
+			Verbose:     r.options.Verbose,
+			NetSim:      simulator,
+			ConnLimiter: connLimiter,
 		})
 		if err != nil {
+			r.Close() //nolint
 			return nil, err
 		}
 		err = serverTCP.LoadTemplate(r.options.RulesFile)
 		if err != nil {
+			r.Close() //nolint
 			return nil, err
 		}
 		watcher, err := watchFile(r.options.RulesFile, serverTCP.LoadTemplate)
 		if err != nil {
+			r.Close() //nolint
 			return nil, err
 		}
-		defer watcher.Close()
-
+		r.watcher = watcher
 		r.serverTCP = serverTCP
+	}
+
+	httpListener, ok := r.options.Listeners.First(ProtocolHTTP, ProtocolHTTPS)
+	if !ok {
 		return &r, nil
 	}
 
+	cacheRules := r.options.CacheRules
+	if r.options.CacheConfig != "" {
+		fileRules, err := httpserver.LoadCacheRules(r.options.CacheConfig)
+		if err != nil {
+			r.Close() //nolint
+			return nil, err
+		}
+		// command line rules take precedence over the file ones
+		cacheRules = append(cacheRules, fileRules...)
+	}
+
+	var rateLimiter *ratelimit.Limiter
+	if r.options.RateLimit > 0 {
+		rateLimiter = ratelimit.New(r.options.RateLimit, r.options.RateBurst)
+	}
+
+	var overlay []string
+	if len(r.options.Folders) > 1 {
+		overlay = r.options.Folders[1:]
+	}
 	httpServer, err := httpserver.New(&httpserver.Options{
 		Folder:            r.options.Folder,
+		Overlay:           overlay,
+		UploadFolder:      r.options.UploadPath,
+		Mounts:            r.options.httpMounts(),
 		EnableUpload:      r.options.EnableUpload,
-		ListenAddress:     r.options.ListenAddress,
-		TLS:               r.options.HTTPS,
+		Mode:              r.options.Mode,
+		UploadConflict:    r.options.UploadConflict,
+		ListenAddress:     httpListener.Address,
+		TLS:               httpListener.Protocol == ProtocolHTTPS,
 		Certificate:       r.options.TLSCertificate,
 		CertificateKey:    r.options.TLSKey,
 		CertificateDomain: r.options.TLSDomain,
@@ -65,14 +148,37 @@ func New(options *Options) (*Runner, error) {
 		BasicAuthReal:     r.options.Realm,
 		Verbose:           r.options.Verbose,
 		Sandbox:           r.options.Sandbox,
+		Include:           r.options.includes,
+		Exclude:           r.options.excludes,
 		MaxFileSize:       r.options.MaxFileSize,
 		HTTP1Only:         r.options.HTTP1Only,
 		MaxDumpBodySize:   unit.ToMb(r.options.MaxDumpBodySize),
+		MaxArchiveSize:    unit.ToMb(r.options.MaxArchiveSize),
 		Python:            r.options.Python,
+		ListingTemplate:   r.options.ListingTemplate,
 		CORS:              r.options.CORS,
 		HTTPHeaders:       r.options.HTTPHeaders,
+		Proxies:           r.options.Proxies,
+		ProxyStripPrefix:  r.options.ProxyStrip,
+		ProxyPreserveHost: r.options.ProxyKeepHost,
+		ProxyHeaders:      r.options.ProxyHeaders,
+		SPA:               r.options.SPA,
+		SPAIndex:          r.options.SPAIndex,
+		Compress:          r.options.Compress,
+		CompressMinSize:   r.options.CompressMinSize,
+		CompressTypes:     splitList(r.options.CompressTypes),
+		Precompressed:     r.options.Precompressed,
+		CacheRules:        cacheRules,
+		ETag:              r.options.ETag,
+		NetSim:            simulator,
+		RateLimiter:       rateLimiter,
+		ConnLimiter:       connLimiter,
+		SignedLinks:       r.options.SignedLinkTTL > 0,
+		HTTP3:             r.options.HTTP3,
+		H2C:               r.options.H2C,
 	})
 	if err != nil {
+		r.Close() //nolint
 		return nil, err
 	}
 	r.httpServer = httpServer
@@ -80,28 +186,270 @@ func New(options *Options) (*Runner, error) {
 	return &r, nil
 }
 
-// Run logic
+// listen on the address, falling back to the port range or a random port when it is in use
+func (r *Runner) listen(listener Listener) (net.Listener, error) {
+	address := listener.Address
+	if listener.Network == NetworkUnix {
+		return binder.ListenUnix(address, r.options.socketMode)
+	}
+	if r.options.PortRange != "" {
+		return binder.ListenInRange(address, r.options.portRangeStart, r.options.portRangeEnd)
+	}
+	netListener, err := net.Listen("tcp", address)
+	if err == nil {
+		return netListener, nil
+	}
+	if r.options.StrictPort {
+		return nil, fmt.Errorf("can't listen on %s (strict-port): %s", address, err)
+	}
+	netListener, err = binder.ListenRandomPort(address)
+	if err != nil {
+		return nil, err
+	}
+	gologger.Print().Msgf("Can't listen on %s - Using %s\n", address, netListener.Addr())
+	return netListener, nil
+}
+
+// inheritListeners adds the listeners passed through socket activation, the protocol is
+// taken from the name of the socket (FileDescriptorName=https) or from the flags
+func (r *Runner) inheritListeners() error {
+	activated, err := binder.ActivationListeners()
+	if err != nil {
+		return err
+	}
+	if len(activated) == 0 {
+		return errors.New("no listener passed through socket activation")
+	}
+	for _, netListener := range activated {
+		listener := Listener{Protocol: r.options.defaultProtocol(), Address: netListener.Addr().String()}
+		switch netListener.Name {
+		case ProtocolHTTP, ProtocolHTTPS, ProtocolTCP, ProtocolTLS:
+			listener.Protocol = netListener.Name
+		}
+		if netListener.Addr().Network() == NetworkUnix {
+			listener.Network = NetworkUnix
+		}
+		r.options.Listeners = append(r.options.Listeners, listener)
+		r.netListeners = append(r.netListeners, netListener.Listener)
+	}
+	if _, ok := r.options.Listeners.First(ProtocolTCP, ProtocolTLS); ok && r.options.RulesFile == "" {
+		return errors.New("TCP listeners require a rules file (-rules)")
+	}
+	return nil
+}
+
+// listenHTTP3 binds the udp port of each https listener on a tcp port
+func (r *Runner) listenHTTP3() error {
+	r.packetConns = make(map[int]net.PacketConn)
+	for i, listener := range r.options.Listeners {
+		if listener.Protocol != ProtocolHTTPS || listener.Network == NetworkUnix {
+			continue
+		}
+		packetConn, err := net.ListenPacket("udp", listener.Address)
+		if err != nil {
+			return fmt.Errorf("can't listen on udp %s for http3: %s", listener.Address, err)
+		}
+		r.packetConns[i] = packetConn
+	}
+	if len(r.packetConns) == 0 {
+		return errors.New("http3 requires an https listener on a tcp port")
+	}
+	return nil
+}
+
+// boundAddress returns the address with the port actually bound by the listener
+func boundAddress(address string, netListener net.Listener) string {
+	host, _, err := net.SplitHostPort(address)
+	tcpAddr, ok := netListener.Addr().(*net.TCPAddr)
+	if err != nil || !ok {
+		return netListener.Addr().String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(tcpAddr.Port))
+}
+
+// writeAddresses writes the bound listeners as json, to stdout when the path is '-'
+func (r *Runner) writeAddresses(path string) error {
+	data, err := json.Marshal(map[string]interface{}{"listeners": r.options.Listeners})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	// written atomically for the processes polling the file
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Run all the listeners until they are closed or one of them fails
 func (r *Runner) Run() error {
-	if r.options.EnableTCP {
-		if r.options.TCPWithTLS {
-			gologger.Print().Msgf("Serving TCP rule based tls server on tcp://%s", r.options.ListenAddress)
-			return r.serverTCP.ListenAndServeTLS()
+	if r.options.AddressFile != "" {
+		if err := r.writeAddresses(r.options.AddressFile); err != nil {
+			r.Close() //nolint
+			return err
 		}
-		gologger.Print().Msgf("Serving TCP rule based server on tcp://%s", r.options.ListenAddress)
-		return r.serverTCP.ListenAndServe()
 	}
 
-	if r.options.HTTPS {
-		gologger.Print().Msgf("Serving %s on https://%s/", r.options.FolderAbsPath(), r.options.ListenAddress)
-		return r.httpServer.ListenAndServeTLS()
+	var serves []func() error
+	for i, listener := range r.options.Listeners {
+		listener, netListener := listener, r.netListeners[i]
+		serves = append(serves, func() error {
+			return r.serve(listener, netListener)
+		})
+		if packetConn, ok := r.packetConns[i]; ok {
+			serves = append(serves, func() error {
+				gologger.Print().Msgf("Serving %s on https://%s/ over http3 (udp)", r.options.FolderAbsPath(), listener.Address)
+				return r.httpServer.ServeHTTP3(packetConn)
+			})
+		}
 	}
 
-	gologger.Print().Msgf("Serving %s on http://%s/", r.options.FolderAbsPath(), r.options.ListenAddress)
-	return r.httpServer.ListenAndServe()
+	errs := make(chan error, len(serves))
+	for _, serve := range serves {
+		go func(serve func() error) {
+			errs <- serve()
+		}(serve)
+	}
+
+	var firstErr error
+	for range serves {
+		err := <-errs
+		// the servers return after a shutdown has been requested
+		if err == nil || errors.Is(err, http.ErrServerClosed) {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+			// a failing listener stops the others
+			r.Close() //nolint
+		}
+	}
+	return firstErr
+}
+
+func (r *Runner) serve(listener Listener, netListener net.Listener) error {
+	switch listener.Protocol {
+	case ProtocolTLS:
+		r.announce(listener, "TCP rule based tls server", "tcp", "")
+		return r.serverTCP.ServeTLS(netListener)
+	case ProtocolTCP:
+		r.announce(listener, "TCP rule based server", "tcp", "")
+		return r.serverTCP.Serve(netListener)
+	case ProtocolHTTPS:
+		r.announce(listener, r.options.FolderAbsPath(), "https", "/")
+		return r.httpServer.ServeTLS(netListener)
+	default:
+		r.announce(listener, r.options.FolderAbsPath(), "http", "/")
+		return r.httpServer.Serve(netListener)
+	}
+}
+
+// announce prints the served listener and the urls reaching it
+func (r *Runner) announce(listener Listener, served, scheme, suffix string) {
+	if listener.Network == NetworkUnix {
+		gologger.Print().Msgf("Serving %s on %s", served, listener)
+		return
+	}
+	gologger.Print().Msgf("Serving %s on %s://%s%s", served, scheme, listener.Address, suffix)
+	r.printReachable(scheme, listener.Address, suffix)
+}
+
+// printReachable prints the urls reaching a listener, signed when enabled
+func (r *Runner) printReachable(scheme, address, suffix string) {
+	urls := listenURLs(scheme, address, suffix)
+	isHTTP := suffix != ""
+	signed := isHTTP && r.options.SignedLinkTTL > 0
+	if signed {
+		for i := range urls {
+			signedURL, err := r.httpServer.SignURL(urls[i].URL, r.options.SignedLinkTTL)
+			if err != nil {
+				gologger.Info().Msgf("Could not sign %s: %s\n", urls[i].URL, err)
+				continue
+			}
+			urls[i].URL = signedURL
+		}
+	}
+
+	// the banner already shows the url of specific addresses
+	if signed || len(urls) != 1 || urls[0].URL != scheme+"://"+address+suffix {
+		for _, url := range urls {
+			if url.Interface != "" {
+				gologger.Print().Msgf("\t%s (%s)", url.URL, url.Interface)
+			} else {
+				gologger.Print().Msgf("\t%s", url.URL)
+			}
+		}
+	}
+
+	// a single qr code is printed, for the first http listener
+	if isHTTP && r.options.QRCode {
+		r.qrOnce.Do(func() {
+			url, ok := selectQRURL(urls, r.options.QRInterface)
+			if !ok {
+				gologger.Info().Msgf("No address found for interface %s\n", r.options.QRInterface)
+				return
+			}
+			printQRCode(url)
+		})
+	}
+}
+
+// Shutdown the listening services waiting for in-flight requests and connections
+func (r *Runner) Shutdown(ctx context.Context) error {
+	var (
+		wg       sync.WaitGroup
+		mux      sync.Mutex
+		firstErr error
+	)
+	shutdown := func(fn func(context.Context) error) {
+		defer wg.Done()
+		if err := fn(ctx); err != nil {
+			mux.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mux.Unlock()
+		}
+	}
+	if r.serverTCP != nil {
+		wg.Add(1)
+		go shutdown(r.serverTCP.Shutdown)
+	}
+	if r.httpServer != nil {
+		wg.Add(1)
+		go shutdown(r.httpServer.Shutdown)
+	}
+	wg.Wait()
+
+	if r.watcher != nil {
+		r.watcher.Close() //nolint
+	}
+	// releases the served folders and archives once drained
+	if r.httpServer != nil {
+		if err := r.httpServer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 // Close the listening services
 func (r *Runner) Close() error {
+	if r.watcher != nil {
+		r.watcher.Close() //nolint
+	}
+	// the sockets are owned by the servers once served, closing twice is harmless
+	for _, netListener := range r.netListeners {
+		netListener.Close() //nolint
+	}
+	for _, packetConn := range r.packetConns {
+		packetConn.Close() //nolint
+	}
 	if r.serverTCP != nil {
 		if err := r.serverTCP.Close(); err != nil {
 			return err
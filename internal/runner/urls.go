@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/skip2/go-qrcode"
+)
+
+// reachableURL is an url reaching a listener through a network interface
+type reachableURL struct {
+	Interface string
+	URL       string
+}
+
+// interfaceAddresses returns the usable addresses of the interfaces which are up
+func interfaceAddresses(ipv4, ipv6 bool) []reachableURL {
+	var addresses []reachableURL
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		gologger.Info().Msgf("Could not list network interfaces: %s\n", err)
+		return addresses
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			// link local addresses would require the zone in the url
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			isIPv4 := ipNet.IP.To4() != nil
+			if isIPv4 && ipv4 || !isIPv4 && ipv6 {
+				addresses = append(addresses, reachableURL{Interface: iface.Name, URL: ipNet.IP.String()})
+			}
+		}
+	}
+	return addresses
+}
+
+// listenURLs returns the urls reaching a listener, with one url per interface address for
+// unspecified addresses (0.0.0.0 is ipv4 only, [::] and empty hosts are dual-stack)
+func listenURLs(scheme, address, suffix string) []reachableURL {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return []reachableURL{{URL: scheme + "://" + address + suffix}}
+	}
+
+	hosts := []reachableURL{{URL: host}}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		hosts = interfaceAddresses(true, host == "" || ip.To4() == nil)
+	}
+
+	urls := make([]reachableURL, 0, len(hosts))
+	for _, host := range hosts {
+		urls = append(urls, reachableURL{
+			Interface: host.Interface,
+			URL:       scheme + "://" + net.JoinHostPort(host.URL, port) + suffix,
+		})
+	}
+	return urls
+}
+
+// printQRCode renders the url as a qr code in the terminal
+func printQRCode(url string) {
+	qr, err := qrcode.New(url, qrcode.Medium)
+	if err != nil {
+		gologger.Info().Msgf("Could not generate qr code: %s\n", err)
+		return
+	}
+	gologger.Print().Msgf("%s\n%s", qr.ToSmallString(false), url)
+}
+
+// selectQRURL returns the url of the interface, or the first non loopback one by default
+func selectQRURL(urls []reachableURL, iface string) (string, bool) {
+	for _, url := range urls {
+		if iface != "" && url.Interface == iface || iface == "" && !isLoopback(url.URL) {
+			return url.URL, true
+		}
+	}
+	if iface == "" && len(urls) > 0 {
+		return urls[0].URL, true
+	}
+	return "", false
+}
+
+// isLoopback returns true if the url host is a loopback address
+func isLoopback(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
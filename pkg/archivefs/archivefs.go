@@ -0,0 +1,39 @@
+package archivefs
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive returns true if the path has the extension of a supported archive (.zip or .tar)
+func IsArchive(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip", ".tar":
+		return true
+	}
+	return false
+}
+
+// Open returns the filesystem of the archive, its files implement io.Seeker.
+// The closer releases the archive once the filesystem is not used anymore.
+func Open(path string) (fs.FS, io.Closer, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		reader, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return Seekable(reader), reader, nil
+	case ".tar":
+		tarFS, err := OpenTar(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tarFS, tarFS, nil
+	}
+	return nil, nil, errors.New("unsupported archive format")
+}
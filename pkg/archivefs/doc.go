@@ -0,0 +1,2 @@
+// Package archivefs exposes zip and tar archives as read-only filesystems
+package archivefs
@@ -0,0 +1,95 @@
+package archivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// Seekable wraps the filesystem so that its files implement io.Seeker, as required by
+// http.FS to serve them. Files which can't seek natively (eg. compressed zip entries)
+// are read up to the offset, and reopened to seek backwards.
+func Seekable(fsys fs.FS) fs.FS {
+	return seekableFS{fsys: fsys}
+}
+
+type seekableFS struct {
+	fsys fs.FS
+}
+
+func (s seekableFS) Open(name string) (fs.File, error) {
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := file.(io.Seeker); ok {
+		return file, nil
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		return file, nil
+	}
+	return &seekableFile{File: file, fsys: s.fsys, name: name, size: info.Size()}, nil
+}
+
+// seekableFile emulates seeking by tracking the offset requested and the one read
+type seekableFile struct {
+	fs.File
+	fsys       fs.FS
+	name       string
+	size       int64
+	offset     int64
+	readOffset int64
+}
+
+func (f *seekableFile) Read(p []byte) (int, error) {
+	if f.offset != f.readOffset {
+		if err := f.moveTo(f.offset); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.File.Read(p)
+	f.offset += int64(n)
+	f.readOffset += int64(n)
+	return n, err
+}
+
+// moveTo reads the underlying file up to the offset, reopening it to move backwards
+func (f *seekableFile) moveTo(offset int64) error {
+	if offset < f.readOffset {
+		file, err := f.fsys.Open(f.name)
+		if err != nil {
+			return err
+		}
+		_ = f.File.Close()
+		f.File = file
+		f.readOffset = 0
+	}
+	if offset > f.size {
+		offset = f.size
+	}
+	n, err := io.CopyN(io.Discard, f.File, offset-f.readOffset)
+	f.readOffset += n
+	return err
+}
+
+func (f *seekableFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
@@ -0,0 +1,183 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TarFS is a read-only filesystem over an uncompressed tar archive, the files are read
+// in place from the archive
+type TarFS struct {
+	file    *os.File
+	modTime time.Time
+	entries map[string]*tarEntry
+}
+
+type tarEntry struct {
+	header   *tar.Header
+	offset   int64
+	children []string
+}
+
+// OpenTar indexes the tar archive, only regular files and folders are exposed
+func OpenTar(name string) (*TarFS, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	t := &TarFS{file: file, modTime: info.ModTime(), entries: make(map[string]*tarEntry)}
+	t.entries["."] = &tarEntry{}
+
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		entryName := path.Clean(strings.TrimPrefix(header.Name, "/"))
+		if !fs.ValidPath(entryName) || entryName == "." {
+			continue
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			// the reader is positioned at the start of the content
+			offset, err := file.Seek(0, io.SeekCurrent)
+			if err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+			t.add(entryName, &tarEntry{header: header, offset: offset})
+		case tar.TypeDir:
+			t.add(entryName, &tarEntry{header: header})
+		}
+	}
+	for _, entry := range t.entries {
+		sort.Strings(entry.children)
+	}
+	return t, nil
+}
+
+// add registers the entry and its missing parent folders
+func (t *TarFS) add(name string, entry *tarEntry) {
+	if existing, ok := t.entries[name]; ok {
+		// a folder header after its content, keep the children
+		entry.children = existing.children
+		t.entries[name] = entry
+		return
+	}
+	t.entries[name] = entry
+	parent := path.Dir(name)
+	if _, ok := t.entries[parent]; !ok {
+		t.add(parent, &tarEntry{})
+	}
+	t.entries[parent].children = append(t.entries[parent].children, path.Base(name))
+}
+
+// Open opens the named file or folder
+func (t *TarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info := t.info(name, entry)
+	if info.IsDir() {
+		return &tarDir{fs: t, name: name, info: info, children: entry.children}, nil
+	}
+	return &tarFile{info: info, SectionReader: io.NewSectionReader(t.file, entry.offset, entry.header.Size)}, nil
+}
+
+// Close releases the archive
+func (t *TarFS) Close() error {
+	return t.file.Close()
+}
+
+func (t *TarFS) info(name string, entry *tarEntry) fs.FileInfo {
+	if entry.header != nil {
+		return entry.header.FileInfo()
+	}
+	// folders without header take the modification time of the archive
+	return dirInfo{name: path.Base(name), modTime: t.modTime}
+}
+
+type tarFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *tarFile) Close() error {
+	return nil
+}
+
+type tarDir struct {
+	fs       *TarFS
+	name     string
+	info     fs.FileInfo
+	children []string
+	offset   int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *tarDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries of the folder, or all the remaining ones if n <= 0
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.children[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(remaining) {
+		remaining = remaining[:n]
+	}
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, child := range remaining {
+		childName := path.Join(d.name, child)
+		entries = append(entries, fs.FileInfoToDirEntry(d.fs.info(childName, d.fs.entries[childName])))
+	}
+	d.offset += len(remaining)
+	return entries, nil
+}
+
+// dirInfo describes the folders implied by the paths of the archive
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (i dirInfo) ModTime() time.Time { return i.modTime }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
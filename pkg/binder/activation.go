@@ -0,0 +1,60 @@
+package binder
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// first file descriptor passed by the service manager
+const listenFdsStart = 3
+
+// ActivationListener is a listener inherited from the service manager
+type ActivationListener struct {
+	net.Listener
+	// Name from the FileDescriptorName setting of the socket unit, if any
+	Name string
+}
+
+// ActivationListeners returns the listeners passed through systemd socket activation
+// (LISTEN_PID, LISTEN_FDS and LISTEN_FDNAMES), the environment is cleared afterwards
+// so that child processes don't inherit them
+func ActivationListeners() ([]ActivationListener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")     //nolint
+		os.Unsetenv("LISTEN_FDS")     //nolint
+		os.Unsetenv("LISTEN_FDNAMES") //nolint
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]ActivationListener, 0, count)
+	for i := 0; i < count; i++ {
+		file := os.NewFile(uintptr(listenFdsStart+i), "LISTEN_FD_"+strconv.Itoa(listenFdsStart+i))
+		listener, err := net.FileListener(file)
+		// the listener uses a duplicate of the descriptor
+		file.Close() //nolint
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close() //nolint
+			}
+			return nil, fmt.Errorf("file descriptor %d is not a listening socket: %s", listenFdsStart+i, err)
+		}
+		activationListener := ActivationListener{Listener: listener}
+		if i < len(names) {
+			activationListener.Name = names[i]
+		}
+		listeners = append(listeners, activationListener)
+	}
+	return listeners, nil
+}
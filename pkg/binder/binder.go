@@ -3,14 +3,15 @@ package binder
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 
-	"github.com/phayes/freeport"
 	"github.com/projectdiscovery/gologger"
 )
 
-// CanListenOn the specified address
+// CanListenOn the specified address, ipv4 or ipv6 (dual-stack for unspecified ipv6 addresses)
 func CanListenOn(address string) bool {
-	listener, err := net.Listen("tcp4", address)
+	listener, err := net.Listen("tcp", address)
 	if err != nil {
 		return false
 	}
@@ -20,17 +21,60 @@ func CanListenOn(address string) bool {
 	return true
 }
 
-// GetRandomListenAddress from the specified one
-func GetRandomListenAddress(currentAddress string) (string, error) {
-	addrOrig, _, err := net.SplitHostPort(currentAddress)
+// ListenRandomPort listens on a free port picked by the kernel, on the host of the address
+func ListenRandomPort(address string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return net.Listen("tcp", net.JoinHostPort(host, "0"))
+}
 
-	newPort, err := freeport.GetFreePort()
+// GetRandomListenAddress from the specified one, keeping the same host and address family
+//
+// Deprecated: the port may be taken again before it is used, use ListenRandomPort instead.
+func GetRandomListenAddress(currentAddress string) (string, error) {
+	listener, err := ListenRandomPort(currentAddress)
 	if err != nil {
 		return "", err
 	}
+	address := listener.Addr().(*net.TCPAddr)
+	if err := listener.Close(); err != nil {
+		gologger.Info().Msgf("%s\n", err)
+	}
+	host, _, _ := net.SplitHostPort(currentAddress)
+	return net.JoinHostPort(host, strconv.Itoa(address.Port)), nil
+}
 
-	return net.JoinHostPort(addrOrig, fmt.Sprintf("%d", newPort)), nil
+// ParsePortRange parses a port range in the form 'start-end'
+func ParsePortRange(value string) (start, end int, err error) {
+	startValue, endValue, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("port range '%s' not in format 'start-end'", value)
+	}
+	if start, err = strconv.Atoi(strings.TrimSpace(startValue)); err != nil {
+		return 0, 0, fmt.Errorf("invalid port range start '%s'", startValue)
+	}
+	if end, err = strconv.Atoi(strings.TrimSpace(endValue)); err != nil {
+		return 0, 0, fmt.Errorf("invalid port range end '%s'", endValue)
+	}
+	if start < 1 || end > 65535 || start > end {
+		return 0, 0, fmt.Errorf("invalid port range '%s'", value)
+	}
+	return start, end, nil
+}
+
+// ListenInRange listens on the first free port of the range, on the host of the address
+func ListenInRange(address string, start, end int) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	for port := start; port <= end; port++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			return listener, nil
+		}
+	}
+	return nil, fmt.Errorf("no free port in range %d-%d on '%s'", start, end, host)
 }
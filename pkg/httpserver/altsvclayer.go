@@ -0,0 +1,19 @@
+package httpserver
+
+import (
+	"net"
+	"net/http"
+)
+
+// altsvclayer advertises the http/3 listener sharing the port of the tcp connection
+func (t *HTTPServer) altsvclayer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// requests already over http/3 have a udp local address
+		if localAddr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			if altSvc := t.altSvc(localAddr); altSvc != "" {
+				w.Header().Set("Alt-Svc", altSvc)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
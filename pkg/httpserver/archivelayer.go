@@ -0,0 +1,225 @@
+package httpserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/projectdiscovery/simplehttpserver/pkg/archivefs"
+)
+
+const (
+	archiveZip   = "zip"
+	archiveTarGz = "tar.gz"
+)
+
+var errArchiveTooLarge = errors.New("archive too large")
+
+// archiveFile is a file or folder included in a directory archive
+type archiveFile struct {
+	upath string
+	name  string
+	info  os.FileInfo
+}
+
+// archivelayer streams the requested folder as a zip or tar.gz archive (?download=zip)
+func (t *HTTPServer) archivelayer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("download")
+		if format == "" || r.Method != http.MethodGet && r.Method != http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		upath := path.Clean("/" + r.URL.Path)
+		dir, err := t.root.Open(upath)
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		info, err := dir.Stat()
+		_ = dir.Close()
+		if err != nil || !info.IsDir() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if format != archiveZip && format != archiveTarGz {
+			http.Error(w, fmt.Sprintf("unsupported archive format '%s'", format), http.StatusBadRequest)
+			return
+		}
+
+		name := path.Base(upath)
+		if upath == "/" {
+			name = t.rootName()
+		}
+		// the files are listed before streaming to enforce the max size
+		var files []archiveFile
+		var total int64
+		err = t.walkArchive(upath, name, func(file archiveFile) error {
+			if !file.info.IsDir() {
+				total += file.info.Size()
+				if t.options.MaxArchiveSize > 0 && total > t.options.MaxArchiveSize {
+					return errArchiveTooLarge
+				}
+			}
+			files = append(files, file)
+			return nil
+		})
+		if errors.Is(err, errArchiveTooLarge) {
+			http.Error(w, "archive exceeds the max size", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		contentType := "application/zip"
+		if format == archiveTarGz {
+			contentType = "application/gzip"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		if format == archiveZip {
+			err = t.writeZip(w, files)
+		} else {
+			err = t.writeTarGz(w, files)
+		}
+		if err != nil {
+			// a truncated archive must not look complete to the client
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// rootName returns the name of the served folder or archive (without extension)
+func (t *HTTPServer) rootName() string {
+	if t.options.Folder == "" {
+		return "root"
+	}
+	name := filepath.Base(t.options.Folder)
+	if archivefs.IsArchive(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// walkArchive calls fn for the folder and its content, skipping symlinks and the entries hidden by the sandbox
+func (t *HTTPServer) walkArchive(upath, name string, fn func(archiveFile) error) error {
+	dir, err := t.root.Open(upath)
+	if err != nil {
+		return err
+	}
+	info, err := dir.Stat()
+	if err != nil {
+		_ = dir.Close()
+		return err
+	}
+	infos, err := dir.Readdir(-1)
+	_ = dir.Close()
+	if err != nil {
+		return err
+	}
+	if err := fn(archiveFile{upath: upath, name: name + "/", info: info}); err != nil {
+		return err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	visible := t.visibilityFilter()
+	for _, info := range infos {
+		entryPath := path.Join(upath, info.Name())
+		// symlinks could point outside of the folder or loop
+		if info.Mode()&os.ModeSymlink != 0 || visible != nil && !visible(entryPath) {
+			continue
+		}
+		entryName := name + "/" + info.Name()
+		if info.IsDir() {
+			err = t.walkArchive(entryPath, entryName, fn)
+		} else if info.Mode().IsRegular() {
+			err = fn(archiveFile{upath: entryPath, name: entryName, info: info})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *HTTPServer) writeZip(w io.Writer, files []archiveFile) error {
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		header, err := zip.FileInfoHeader(file.info)
+		if err != nil {
+			return err
+		}
+		header.Name = file.name
+		if file.info.IsDir() {
+			if _, err := zw.CreateHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+		header.Method = zip.Deflate
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if err := t.copyFile(entry, file); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (t *HTTPServer) writeTarGz(w io.Writer, files []archiveFile) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, file := range files {
+		header, err := tar.FileInfoHeader(file.info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = file.name
+		// the local owners are meaningless to the client
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if file.info.IsDir() {
+			continue
+		}
+		if err := t.copyFile(tw, file); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyFile writes exactly the listed size, files changed in the meantime fail the archive
+func (t *HTTPServer) copyFile(w io.Writer, file archiveFile) error {
+	f, err := t.root.Open(file.upath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(w, f, file.info.Size())
+	return err
+}
@@ -5,15 +5,29 @@ import (
 	"net/http"
 )
 
+// authenticatedUser returns the username if the request carries valid credentials
+func (t *HTTPServer) authenticatedUser(r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != t.options.BasicAuthUsername || pass != t.options.BasicAuthPassword {
+		return "", false
+	}
+	return user, true
+}
+
 func (t *HTTPServer) basicauthlayer(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok || user != t.options.BasicAuthUsername || pass != t.options.BasicAuthPassword {
-			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=\"%s\"", t.options.BasicAuthReal))
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Unauthorized.\n")) //nolint
+		if _, ok := t.authenticatedUser(r); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if ok, redirected := t.tokenAuthenticated(w, r); ok {
+			if !redirected {
+				handler.ServeHTTP(w, r)
+			}
 			return
 		}
-		handler.ServeHTTP(w, r)
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=\"%s\"", t.options.BasicAuthReal))
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized.\n")) //nolint
 	})
 }
@@ -0,0 +1,176 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CacheRule sets the Cache-Control header of files matching a glob pattern
+type CacheRule struct {
+	Match string `yaml:"match"`
+	Value string `yaml:"value"`
+}
+
+// CacheConfiguration from yaml
+type CacheConfiguration struct {
+	Rules []CacheRule `yaml:"cache-control"`
+}
+
+// LoadCacheRules reads the Cache-Control rules from a yaml file
+func LoadCacheRules(configPath string) ([]CacheRule, error) {
+	var config CacheConfiguration
+	yamlFile, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(yamlFile, &config); err != nil {
+		return nil, err
+	}
+	for _, rule := range config.Rules {
+		if _, err := path.Match(rule.Match, ""); err != nil {
+			return nil, fmt.Errorf("invalid cache-control pattern '%s': %w", rule.Match, err)
+		}
+	}
+	return config.Rules, nil
+}
+
+// Matches returns true if the pattern matches the request path. Patterns without
+// a slash are matched against the file name, the others against the full path
+func (c CacheRule) Matches(requestPath string) bool {
+	requestPath = strings.TrimPrefix(requestPath, "/")
+	target := requestPath
+	if !strings.Contains(c.Match, "/") {
+		target = path.Base(requestPath)
+	}
+	matched, err := path.Match(strings.TrimPrefix(c.Match, "/"), target)
+	return err == nil && matched
+}
+
+// maxETagEntries bounds the number of hashed files kept in memory
+const maxETagEntries = 10000
+
+// etagEntry is the content hash of a file version, identified without reading its content
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// etagCache stores the content hash of files by identity, a modified file replaces its entry
+type etagCache struct {
+	mux   sync.RWMutex
+	etags map[string]etagEntry
+}
+
+func (c *etagCache) get(upath string, file http.File, info os.FileInfo) (string, error) {
+	id := fileID(upath, info)
+	c.mux.RLock()
+	entry, ok := c.etags[id]
+	c.mux.RUnlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.etag, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(hash.Sum(nil)[:16]))
+
+	c.mux.Lock()
+	if _, ok := c.etags[id]; !ok && len(c.etags) >= maxETagEntries {
+		// evict any entry, it is hashed again on its next request
+		for evicted := range c.etags {
+			delete(c.etags, evicted)
+			break
+		}
+	}
+	c.etags[id] = etagEntry{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	c.mux.Unlock()
+	return etag, nil
+}
+
+// cachelayer sets Cache-Control policies and content based ETags on served files,
+// it sits behind the authentication and the access mode so only allowed requests hash files
+func (t *HTTPServer) cachelayer(handler http.Handler) http.Handler {
+	cache := &etagCache{etags: make(map[string]etagEntry)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, rule := range t.options.CacheRules {
+			if rule.Matches(requestedPath(r)) {
+				w.Header().Set("Cache-Control", rule.Value)
+				break
+			}
+		}
+
+		if t.options.ETag && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			// http.ServeContent takes care of If-None-Match once the header is set
+			if etag, ok := t.fileETag(cache, r.URL.Path); ok {
+				w.Header().Set("ETag", etag)
+				w = &validatorResponseWriter{ResponseWriter: w}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// validatorResponseWriter drops the ETag from the responses which don't carry the file
+type validatorResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (vw *validatorResponseWriter) WriteHeader(code int) {
+	if vw.wroteHeader {
+		return
+	}
+	vw.wroteHeader = true
+	switch code {
+	case http.StatusOK, http.StatusPartialContent, http.StatusNotModified:
+	default:
+		vw.Header().Del("ETag")
+	}
+	vw.ResponseWriter.WriteHeader(code)
+}
+
+func (vw *validatorResponseWriter) Write(data []byte) (int, error) {
+	if !vw.wroteHeader {
+		vw.WriteHeader(http.StatusOK)
+	}
+	return vw.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the original response writer
+func (vw *validatorResponseWriter) Unwrap() http.ResponseWriter {
+	return vw.ResponseWriter
+}
+
+func (t *HTTPServer) fileETag(cache *etagCache, requestPath string) (string, bool) {
+	upath := path.Clean("/" + requestPath)
+	file, err := t.root.Open(upath)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	etag, err := cache.get(upath, file, info)
+	if err != nil {
+		return "", false
+	}
+	return etag, true
+}
@@ -0,0 +1,382 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+)
+
+// DefaultCompressTypes contains the content types compressed when none are specified
+var DefaultCompressTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// encodings supported by the server, in order of preference
+var supportedEncodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+
+// precompressedExtensions maps each encoding to the extension of the precompressed sibling
+var precompressedExtensions = map[string]string{
+	encodingBrotli: ".br",
+	encodingZstd:   ".zst",
+	encodingGzip:   ".gz",
+}
+
+// negotiateEncoding picks the best supported encoding allowed by the Accept-Encoding header
+func negotiateEncoding(acceptEncoding string, available []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, token := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(token), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range available {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func newEncoder(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case encodingBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case encodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	case encodingGzip:
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	}
+	return nil, errors.New("unsupported encoding")
+}
+
+// compresslayer negotiates the response encoding and serves precompressed siblings when present
+func (t *HTTPServer) compresslayer(handler http.Handler) http.Handler {
+	types := t.options.CompressTypes
+	if len(types) == 0 {
+		types = DefaultCompressTypes
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		acceptEncoding := r.Header.Get("Accept-Encoding")
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if t.options.Precompressed && t.servePrecompressed(w, r, handler, acceptEncoding) {
+			return
+		}
+
+		// dynamic compression would break byte ranges, serve them untouched
+		encoding := negotiateEncoding(acceptEncoding, supportedEncodings)
+		if !t.options.Compress || encoding == "" || r.Header.Get("Range") != "" || r.Method == http.MethodHead {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        t.options.CompressMinSize,
+			types:          types,
+			statusCode:     http.StatusOK,
+		}
+		defer cw.Close() //nolint
+		handler.ServeHTTP(cw, r)
+	})
+}
+
+// servePrecompressed serves file.ext.br / .zst / .gz if the client accepts them and they exist
+func (t *HTTPServer) servePrecompressed(w http.ResponseWriter, r *http.Request, handler http.Handler, acceptEncoding string) bool {
+	upath := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") || upath == "/" || !t.servesSiblings(r, upath) {
+		return false
+	}
+
+	available := append([]string(nil), supportedEncodings...)
+	for len(available) > 0 {
+		encoding := negotiateEncoding(acceptEncoding, available)
+		if encoding == "" {
+			return false
+		}
+		if t.serveSibling(w, r, handler, upath, encoding) {
+			return true
+		}
+		for i, candidate := range available {
+			if candidate == encoding {
+				available = append(available[:i], available[i+1:]...)
+				break
+			}
+		}
+	}
+	return false
+}
+
+// servesSiblings excludes the requests the siblings must not answer: proxied paths,
+// hidden files and signed links (their redirect targets the requested path)
+func (t *HTTPServer) servesSiblings(r *http.Request, upath string) bool {
+	for _, rule := range t.options.Proxies {
+		if matchPrefix(rule.Prefix, upath) {
+			return false
+		}
+	}
+	return !t.hidden(upath) && !r.URL.Query().Has(tokenParam)
+}
+
+// serveSibling rewrites the request to the sibling so that it goes through the inner
+// layers (authentication, access mode, ...) like the original file would
+func (t *HTTPServer) serveSibling(w http.ResponseWriter, r *http.Request, handler http.Handler, upath, encoding string) bool {
+	sibling := upath + precompressedExtensions[encoding]
+	file, err := t.root.Open(sibling)
+	if err != nil {
+		return false
+	}
+	info, err := file.Stat()
+	_ = file.Close()
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(upath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// the inner cache layer hashes the sibling, giving each representation its own validator
+	rewritten := r.Clone(context.WithValue(r.Context(), siblingContextKey{}, upath))
+	rewritten.URL.Path = sibling
+	rewritten.URL.RawPath = ""
+	handler.ServeHTTP(&siblingResponseWriter{ResponseWriter: w, contentType: contentType, encoding: encoding}, rewritten)
+	return true
+}
+
+// siblingContextKey keeps the requested path of the requests rewritten to a sibling
+type siblingContextKey struct{}
+
+// requestedPath returns the path asked by the client, before any rewrite to a sibling
+func requestedPath(r *http.Request) string {
+	if upath, ok := r.Context().Value(siblingContextKey{}).(string); ok {
+		return upath
+	}
+	return r.URL.Path
+}
+
+// siblingResponseWriter labels the successful responses with the type of the original file,
+// the errors of the inner layers are sent as they are
+type siblingResponseWriter struct {
+	http.ResponseWriter
+	contentType string
+	encoding    string
+	wroteHeader bool
+}
+
+func (sw *siblingResponseWriter) WriteHeader(code int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.wroteHeader = true
+	if code >= 200 && code < 300 || code == http.StatusNotModified {
+		sw.Header().Set("Content-Type", sw.contentType)
+		sw.Header().Set("Content-Encoding", sw.encoding)
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *siblingResponseWriter) Write(data []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	return sw.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the original response writer
+func (sw *siblingResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// compressResponseWriter compresses the body once it knows the response is worth compressing
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	minSize     int
+	types       []string
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	buffer      []byte
+	encoder     io.WriteCloser
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.statusCode = code
+	// small or unknown bodies are decided on the first write
+	if !cw.compressible() {
+		cw.decide(false)
+	} else if contentLength := cw.Header().Get("Content-Length"); contentLength != "" {
+		size, err := strconv.Atoi(contentLength)
+		cw.decide(err == nil && size >= cw.minSize)
+	}
+}
+
+func (cw *compressResponseWriter) Write(data []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(data)
+		}
+		return cw.ResponseWriter.Write(data)
+	}
+
+	cw.buffer = append(cw.buffer, data...)
+	if len(cw.buffer) >= cw.minSize {
+		cw.decide(true)
+		if err := cw.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// compressible checks status, existing encoding and content type of the response
+func (cw *compressResponseWriter) compressible() bool {
+	if cw.statusCode != http.StatusOK || cw.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := cw.Header().Get("Content-Type")
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range cw.types {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed) || mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (cw *compressResponseWriter) decide(compress bool) {
+	cw.decided = true
+	if compress {
+		encoder, err := newEncoder(cw.encoding, cw.ResponseWriter)
+		if err == nil {
+			headers := cw.Header()
+			headers.Set("Content-Encoding", cw.encoding)
+			headers.Del("Content-Length")
+			headers.Del("Accept-Ranges")
+			if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				headers.Set("ETag", "W/"+etag)
+			}
+			cw.encoder = encoder
+		}
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+func (cw *compressResponseWriter) flushBuffer() error {
+	if len(cw.buffer) == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buffer)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buffer)
+	}
+	cw.buffer = nil
+	return err
+}
+
+// Close writes any pending data and terminates the compressed stream
+func (cw *compressResponseWriter) Close() error {
+	if !cw.wroteHeader {
+		// nothing was written by the handler
+		return nil
+	}
+	if !cw.decided {
+		cw.decide(false)
+		if err := cw.flushBuffer(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// Flush sends any buffered data to the client
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided && cw.wroteHeader {
+		cw.decide(len(cw.buffer) >= cw.minSize)
+		_ = cw.flushBuffer()
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection (eg. websocket upgrades)
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	cw.decided = true
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the original response writer
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package httpserver
+
+import "os"
+
+// fileID returns the path of the file as inodes are not available
+func fileID(name string, info os.FileInfo) string {
+	return name
+}
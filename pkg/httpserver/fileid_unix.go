@@ -0,0 +1,17 @@
+//go:build unix
+
+package httpserver
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode of the file, falling back to its path
+func fileID(name string, info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", uint64(stat.Dev), uint64(stat.Ino)) //nolint
+	}
+	return name
+}
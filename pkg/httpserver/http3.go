@@ -0,0 +1,75 @@
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// quicTLSConfig returns the tls configuration of the quic listeners, which can't load the
+// certificate files on their own
+func (t *HTTPServer) quicTLSConfig() (*tls.Config, error) {
+	if t.options.Certificate != "" && t.options.CertificateKey != "" {
+		cert, err := tls.LoadX509KeyPair(t.options.Certificate, t.options.CertificateKey)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+	return t.makeTLSConfig()
+}
+
+// ServeHTTP3 requests over http/3 on the udp connection
+func (t *HTTPServer) ServeHTTP3(conn net.PacketConn) error {
+	tlsConfig, err := t.quicTLSConfig()
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if udpAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		t.addHTTP3Port(udpAddr.Port)
+	}
+	h3Server := &http3.Server{
+		TLSConfig: http3.ConfigureTLSConfig(tlsConfig),
+		Handler:   t.layers,
+		ConnContext: func(ctx context.Context, c *quic.Conn) context.Context {
+			return t.connContext(ctx, nil)
+		},
+	}
+	if err := t.trackHTTP3Server(h3Server); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	err = h3Server.Serve(conn)
+	// the connection is not owned by the http/3 server
+	_ = conn.Close()
+	return err
+}
+
+// addHTTP3Port registers a port served over http/3, advertised by the tcp listener on the same port
+func (t *HTTPServer) addHTTP3Port(port int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.http3Ports == nil {
+		t.http3Ports = make(map[int]struct{})
+	}
+	t.http3Ports[port] = struct{}{}
+}
+
+// altSvc returns the Alt-Svc header value for requests received on the local address
+func (t *HTTPServer) altSvc(localAddr net.Addr) string {
+	tcpAddr, ok := localAddr.(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if _, ok := t.http3Ports[tcpAddr.Port]; !ok {
+		return ""
+	}
+	return `h3=":` + strconv.Itoa(tcpAddr.Port) + `"; ma=86400`
+}
@@ -1,18 +1,37 @@
 package httpserver
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sync"
 
+	"github.com/projectdiscovery/simplehttpserver/pkg/archivefs"
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+	"github.com/projectdiscovery/simplehttpserver/pkg/ratelimit"
 	"github.com/projectdiscovery/sslcert"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Options of the http server
 type Options struct {
 	Folder            string
+	Overlay           []string
+	UploadFolder      string
+	Mounts            []Mount
+	Include           []string
+	Exclude           []string
 	EnableUpload      bool
 	ListenAddress     string
 	TLS               bool
@@ -27,43 +46,233 @@ type Options struct {
 	HTTP1Only         bool
 	MaxFileSize       int // 50Mb
 	MaxDumpBodySize   int64
+	MaxArchiveSize    int64
+	Mode              string
+	UploadConflict    string
 	Python            bool
+	ListingTemplate   string
 	CORS              bool
 	HTTPHeaders       []HTTPHeader
+	Proxies           []ProxyRule
+	ProxyStripPrefix  bool
+	ProxyPreserveHost bool
+	ProxyHeaders      []HTTPHeader
+	SPA               bool
+	SPAIndex          string
+	Compress          bool
+	CompressMinSize   int
+	CompressTypes     []string
+	Precompressed     bool
+	CacheRules        []CacheRule
+	ETag              bool
+	NetSim            *netsim.Simulator
+	RateLimiter       *ratelimit.Limiter
+	ConnLimiter       *ratelimit.ConnLimiter
+	SignedLinks       bool
+	HTTP3             bool
+	H2C               bool
 }
 
 // HTTPServer instance
 type HTTPServer struct {
-	options *Options
-	layers  http.Handler
+	options    *Options
+	root       http.FileSystem
+	layers     http.Handler
+	signingKey []byte
+	rootCloser io.Closer
+	filter     *PathFilter
+
+	mux        sync.Mutex
+	servers    []*http.Server
+	h3Servers  []*http3.Server
+	http3Ports map[int]struct{}
+	closed     bool
+	tlsConfig  *tls.Config
 }
 
 // LayerHandler is the interface of all layer funcs
 type Middleware func(http.Handler) http.Handler
 
-// New http server instance with options
+// New http server instance with options, serving a folder or a zip/tar archive (read-only).
+// The overlay folders are merged below the folder, the first ones shadowing the others.
+// With mounts and without a folder only the mount points are served.
 func New(options *Options) (*HTTPServer, error) {
-	var h HTTPServer
-	EnableUpload = options.EnableUpload
-	EnableVerbose = options.Verbose
-	folder, err := filepath.Abs(options.Folder)
+	if err := applyMode(options); err != nil {
+		return nil, err
+	}
+	var (
+		dir     http.FileSystem
+		closers multiCloser
+		err     error
+	)
+	if options.Folder != "" || len(options.Mounts) == 0 {
+		if dir, err = openFolders(options, &closers); err != nil {
+			_ = closers.Close()
+			return nil, err
+		}
+	}
+
+	if len(options.Mounts) > 0 {
+		mfs := &mountFileSystem{root: dir}
+		for _, mount := range options.Mounts {
+			mount.Prefix = path.Clean("/" + mount.Prefix)
+			if mount.Prefix == "/" {
+				_ = closers.Close()
+				return nil, errors.New("mount prefix can't be '/', use the folder instead")
+			}
+			if mount.Folder, err = filepath.Abs(mount.Folder); err != nil {
+				_ = closers.Close()
+				return nil, err
+			}
+			layer, closer, err := openRoot(mount.Folder, mount.Upload, mount.Sandbox, options.Include)
+			if err != nil {
+				_ = closers.Close()
+				return nil, err
+			}
+			if closer != nil {
+				closers = append(closers, closer)
+			}
+			mfs.mounts = append(mfs.mounts, mount)
+			mfs.fss = append(mfs.fss, layer)
+		}
+		options.Mounts = mfs.mounts
+		dir = mfs
+	}
+
+	h, err := newServer(options, dir)
+	if err != nil {
+		_ = closers.Close()
+		return nil, err
+	}
+	if len(closers) > 0 {
+		h.rootCloser = closers
+	}
+	return h, nil
+}
+
+// openFolders returns the filesystem of the folder merged with the overlay ones
+func openFolders(options *Options, closers *multiCloser) (http.FileSystem, error) {
+	folders := append([]string{options.Folder}, options.Overlay...)
+	for i, folder := range folders {
+		abspath, err := filepath.Abs(folder)
+		if err != nil {
+			return nil, err
+		}
+		folders[i] = abspath
+	}
+	options.Folder, options.Overlay = folders[0], folders[1:]
+
+	if options.UploadFolder == "" {
+		options.UploadFolder = options.Folder
+	}
+	uploadFolder, err := filepath.Abs(options.UploadFolder)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := os.Stat(folder); os.IsNotExist(err) {
-		return nil, errors.New("path does not exist")
+	options.UploadFolder = uploadFolder
+	if !slices.Contains(folders, uploadFolder) {
+		return nil, errors.New("upload path must be one of the served paths")
+	}
+
+	var layers []http.FileSystem
+	for _, folder := range folders {
+		layer, closer, err := openRoot(folder, options.EnableUpload && folder == options.UploadFolder, options.Sandbox, options.Include)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+		if closer != nil {
+			*closers = append(*closers, closer)
+		}
+	}
+	if len(layers) == 1 {
+		return layers[0], nil
+	}
+	return OverlayFileSystem{Layers: layers}, nil
+}
+
+// openRoot returns the filesystem of a folder (sandboxed if enabled) or of an archive
+func openRoot(folder string, upload, sandbox bool, include []string) (http.FileSystem, io.Closer, error) {
+	info, err := os.Stat(folder)
+	if os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("path %s does not exist", folder)
+	}
+
+	if err == nil && !info.IsDir() && archivefs.IsArchive(folder) {
+		if upload || sandbox {
+			return nil, nil, errors.New("archives are read-only and can't be used with upload or sandbox")
+		}
+		fsys, closer, err := archivefs.Open(folder)
+		if err != nil {
+			return nil, nil, err
+		}
+		return http.FS(fsys), closer, nil
+	}
+
+	if sandbox {
+		sbfs, err := NewSandboxFileSystem(folder)
+		if err != nil {
+			return nil, nil, err
+		}
+		sbfs.Include = include
+		return sbfs, sbfs, nil
+	}
+	return http.Dir(folder), nil, nil
+}
+
+// multiCloser closes all the closers, returning the first error
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var err error
+	for _, closer := range m {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// NewFS http server instance serving the filesystem (eg. embed.FS) read-only,
+// the folder of the options is ignored
+func NewFS(fsys fs.FS, options *Options) (*HTTPServer, error) {
+	if err := applyMode(options); err != nil {
+		return nil, err
+	}
+	if options.EnableUpload || options.Sandbox {
+		return nil, errors.New("filesystems are read-only and can't be used with upload or sandbox")
 	}
-	options.Folder = folder
-	var dir http.FileSystem
-	dir = http.Dir(options.Folder)
-	if options.Sandbox {
-		dir = SandboxFileSystem{fs: http.Dir(options.Folder), RootFolder: options.Folder}
+	return newServer(options, http.FS(archivefs.Seekable(fsys)))
+}
+
+func newServer(options *Options, dir http.FileSystem) (*HTTPServer, error) {
+	filter := &PathFilter{Include: options.Include, Exclude: options.Exclude}
+	if options.UploadConflict == ConflictVersion {
+		// the versions are only reachable with the version queries
+		filter.Exclude = append(slices.Clone(options.Exclude), versionsFolder)
+	}
+	if len(filter.Exclude) > 0 {
+		dir = filterFileSystem{fs: dir, filter: filter}
+	}
+	h := HTTPServer{options: options, root: dir, filter: filter}
+	if options.SignedLinks {
+		var err error
+		if h.signingKey, err = newSigningKey(); err != nil {
+			return nil, err
+		}
 	}
 
 	var httpHandler http.Handler
-	if options.Python {
-		httpHandler = PythonStyle(dir.(http.Dir))
-	} else {
+	switch {
+	case options.ListingTemplate != "":
+		tmpl, err := LoadListingTemplate(options.ListingTemplate)
+		if err != nil {
+			return nil, err
+		}
+		httpHandler = newListingHandler(dir, tmpl, h.visibilityFilter())
+	case options.Python:
+		httpHandler = newListingHandler(dir, pythonTemplate, h.visibilityFilter())
+	default:
 		httpHandler = http.FileServer(dir)
 	}
 
@@ -72,10 +281,33 @@ func New(options *Options) (*HTTPServer, error) {
 	}
 
 	// middleware
-	if options.EnableUpload {
+	addHandler(h.listinglayer)
+	addHandler(h.archivelayer)
+
+	if options.SPA {
+		addHandler(h.spalayer)
+	}
+
+	if options.EnableUpload || slices.ContainsFunc(options.Mounts, func(mount Mount) bool { return mount.Upload }) {
 		addHandler(h.uploadlayer)
 	}
 
+	if options.UploadConflict == ConflictVersion {
+		addHandler(h.versionlayer)
+	}
+
+	if len(options.CacheRules) > 0 || options.ETag {
+		addHandler(h.cachelayer)
+	}
+
+	if options.Mode != "" {
+		addHandler(h.modelayer)
+	}
+
+	if len(options.Proxies) > 0 {
+		addHandler(h.proxylayer)
+	}
+
 	if options.BasicAuthUsername != "" || options.BasicAuthPassword != "" {
 		addHandler(h.basicauthlayer)
 	}
@@ -84,12 +316,26 @@ func New(options *Options) (*HTTPServer, error) {
 		addHandler(h.corslayer)
 	}
 
+	if options.Compress || options.Precompressed {
+		addHandler(h.compresslayer)
+	}
+
+	if options.RateLimiter != nil {
+		addHandler(h.ratelimitlayer)
+	}
+
+	if options.HTTP3 {
+		addHandler(h.altsvclayer)
+	}
+
 	httpHandler = h.loglayer(httpHandler)
+	if options.NetSim != nil && options.NetSim.Enabled() {
+		httpHandler = h.netsimlayer(httpHandler)
+	}
 	httpHandler = h.headerlayer(httpHandler, options.HTTPHeaders)
 
 	// add handler
 	h.layers = httpHandler
-	h.options = options
 
 	return &h, nil
 }
@@ -101,31 +347,175 @@ func (t *HTTPServer) makeHTTPServer(tlsConfig *tls.Config) *http.Server {
 	}
 	httpServer.TLSConfig = tlsConfig
 	httpServer.Handler = t.layers
+	httpServer.ConnContext = t.connContext
+	if tlsConfig == nil && t.options.H2C {
+		// prior knowledge and upgrade based http/2 over cleartext, the connections are
+		// hijacked from the server and closed by the http/2 server on shutdown
+		h2Server := &http2.Server{}
+		_ = http2.ConfigureServer(httpServer, h2Server)
+		httpServer.Handler = h2c.NewHandler(t.layers, h2Server)
+	}
 	return httpServer
 }
 
-// ListenAndServe requests over http
-func (t *HTTPServer) ListenAndServe() error {
-	httpServer := t.makeHTTPServer(nil)
-	return httpServer.ListenAndServe()
+// ServeHTTP serves the request through the layers, without any listener
+func (t *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.layers.ServeHTTP(w, r)
 }
 
-// ListenAndServeTLS requests over https
-func (t *HTTPServer) ListenAndServeTLS() error {
-	if t.options.Certificate == "" || t.options.CertificateKey == "" {
+// trackServer registers the server to stop it on shutdown
+func (t *HTTPServer) trackServer(httpServer *http.Server) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		return http.ErrServerClosed
+	}
+	t.servers = append(t.servers, httpServer)
+	return nil
+}
+
+// trackHTTP3Server registers the http/3 server to stop it on shutdown
+func (t *HTTPServer) trackHTTP3Server(h3Server *http3.Server) error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		return http.ErrServerClosed
+	}
+	t.h3Servers = append(t.h3Servers, h3Server)
+	return nil
+}
+
+// closeServers prevents new servers from starting and returns the running ones
+func (t *HTTPServer) closeServers() ([]*http.Server, []*http3.Server) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.closed = true
+	return append([]*http.Server(nil), t.servers...), append([]*http3.Server(nil), t.h3Servers...)
+}
+
+// makeTLSConfig returns the self-signed certificate configuration when no certificate is provided
+func (t *HTTPServer) makeTLSConfig() (*tls.Config, error) {
+	if t.options.Certificate != "" && t.options.CertificateKey != "" {
+		// the certificate files are loaded by ServeTLS
+		return &tls.Config{}, nil
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	// the certificate is generated once and shared by all the listeners
+	if t.tlsConfig == nil {
 		tlsOptions := sslcert.DefaultOptions
 		tlsOptions.Host = t.options.CertificateDomain
 		tlsConfig, err := sslcert.NewTLSConfig(tlsOptions)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		t.tlsConfig = tlsConfig
+	}
+	return t.tlsConfig.Clone(), nil
+}
+
+// limitListener applies the connection limits to the listener
+func (t *HTTPServer) limitListener(listener net.Listener) net.Listener {
+	if t.options.ConnLimiter != nil {
+		return ratelimit.NewListener(listener, t.options.ConnLimiter)
+	}
+	return listener
+}
+
+// ListenAndServe requests over http
+func (t *HTTPServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", t.options.ListenAddress)
+	if err != nil {
+		return err
+	}
+	return t.Serve(listener)
+}
+
+// ListenAndServeTLS requests over https
+func (t *HTTPServer) ListenAndServeTLS() error {
+	listener, err := net.Listen("tcp", t.options.ListenAddress)
+	if err != nil {
+		return err
+	}
+	return t.ServeTLS(listener)
+}
+
+// Serve requests over http on the listener
+func (t *HTTPServer) Serve(listener net.Listener) error {
+	httpServer := t.makeHTTPServer(nil)
+	if err := t.trackServer(httpServer); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	return httpServer.Serve(t.limitListener(listener))
+}
+
+// ServeTLS requests over https on the listener
+func (t *HTTPServer) ServeTLS(listener net.Listener) error {
+	tlsConfig, err := t.makeTLSConfig()
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	httpServer := t.makeHTTPServer(tlsConfig)
+	if err := t.trackServer(httpServer); err != nil {
+		_ = listener.Close()
+		return err
+	}
+	return httpServer.ServeTLS(t.limitListener(listener), t.options.Certificate, t.options.CertificateKey)
+}
+
+// Shutdown gracefully stops the servers waiting for in-flight requests,
+// connections still active when the context expires are closed
+func (t *HTTPServer) Shutdown(ctx context.Context) error {
+	servers, h3Servers := t.closeServers()
+	errs := make(chan error, len(servers)+len(h3Servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			err := server.Shutdown(ctx)
+			if ctx.Err() != nil {
+				_ = server.Close()
+			}
+			errs <- err
+		}(server)
+	}
+	for _, h3Server := range h3Servers {
+		go func(h3Server *http3.Server) {
+			err := h3Server.Shutdown(ctx)
+			if ctx.Err() != nil {
+				_ = h3Server.Close()
+			}
+			errs <- err
+		}(h3Server)
+	}
+
+	var firstErr error
+	for i := 0; i < len(servers)+len(h3Servers); i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
 		}
-		httpServer := t.makeHTTPServer(tlsConfig)
-		return httpServer.ListenAndServeTLS("", "")
 	}
-	return http.ListenAndServeTLS(t.options.ListenAddress, t.options.Certificate, t.options.CertificateKey, t.layers)
+	return firstErr
 }
 
 // Close the service
 func (t *HTTPServer) Close() error {
-	return nil
+	var firstErr error
+	servers, h3Servers := t.closeServers()
+	for _, server := range servers {
+		if err := server.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, h3Server := range h3Servers {
+		if err := h3Server.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if t.rootCloser != nil {
+		// served archives are released once no request can read them anymore
+		t.rootCloser.Close() //nolint
+	}
+	return firstErr
 }
@@ -0,0 +1,251 @@
+package httpserver
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// built-in listing templates, selected by name instead of a template file
+const (
+	ListingTemplatePython = "python"
+	ListingTemplateRich   = "rich"
+)
+
+const richListingTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Index of {{.Path}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 1em 0.3em 0; }
+td.size, th.size { text-align: right; }
+tr:hover { background: #f0f0f0; }
+nav a { text-decoration: none; }
+</style>
+</head>
+<body>
+<nav>{{range $i, $crumb := .Breadcrumbs}}{{if $i}} / {{end}}<a href="{{$crumb.URL}}">{{$crumb.Name}}</a>{{end}}</nav>
+<form method="get">
+<input type="search" name="q" value="{{.Filter}}" placeholder="Filter" autofocus>
+<input type="hidden" name="sort" value="{{.Sort}}">
+<input type="hidden" name="order" value="{{.Order}}">
+</form>
+<p>Download: <a href="?download=zip">zip</a> | <a href="?download=tar.gz">tar.gz</a></p>
+<table>
+<tr><th><a href="{{.SortURL "name"}}">Name</a>{{.SortArrow "name"}}</th><th class="size"><a href="{{.SortURL "size"}}">Size</a>{{.SortArrow "size"}}</th><th><a href="{{.SortURL "date"}}">Modified</a>{{.SortArrow "date"}}</th></tr>
+{{if ne .Path "/"}}<tr><td><a href="../">../</a></td><td></td><td></td></tr>
+{{end}}{{range .Entries}}<tr><td><a href="{{.URL}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td class="size">{{if not .IsDir}}{{size .Size}}{{end}}</td><td>{{date .ModTime}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+// listingFuncs are the functions available to the listing templates
+var listingFuncs = template.FuncMap{
+	"size": humanSize,
+	"date": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+}
+
+// humanSize formats a size in bytes with a binary unit (eg. 1.5 KB)
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// LoadListingTemplate returns a built-in template by name (python, rich) or parses an html/template file.
+// The template receives the folder path, breadcrumbs, entries, sort order and filter.
+func LoadListingTemplate(nameOrPath string) (*template.Template, error) {
+	switch nameOrPath {
+	case ListingTemplatePython:
+		return pythonTemplate, nil
+	case ListingTemplateRich:
+		return template.New(nameOrPath).Funcs(listingFuncs).Parse(richListingTemplate)
+	}
+	return template.New(filepath.Base(nameOrPath)).Funcs(listingFuncs).ParseFiles(nameOrPath)
+}
+
+// breadcrumb is a parent folder of the listed one
+type breadcrumb struct {
+	Name string
+	URL  string
+}
+
+// listingPage is the data of the listing templates
+type listingPage struct {
+	Path        string
+	Breadcrumbs []breadcrumb
+	Entries     []*listingEntry
+	Sort        string
+	Order       string
+	Filter      string
+}
+
+// SortURL returns the query sorting by the column, reversing the order if it is the current one
+func (p *listingPage) SortURL(column string) string {
+	order := "asc"
+	if p.Sort == column && p.Order == "asc" {
+		order = "desc"
+	}
+	query := url.Values{"sort": {column}, "order": {order}}
+	if p.Filter != "" {
+		query.Set("q", p.Filter)
+	}
+	return "?" + query.Encode()
+}
+
+// SortArrow returns the indicator of the column the entries are sorted by
+func (p *listingPage) SortArrow(column string) string {
+	switch {
+	case p.Sort != column:
+		return ""
+	case p.Order == "desc":
+		return " ▼"
+	default:
+		return " ▲"
+	}
+}
+
+// newBreadcrumbs returns the links to the folders containing the path
+func newBreadcrumbs(upath string) []breadcrumb {
+	crumbs := []breadcrumb{{Name: "/", URL: "/"}}
+	current := "/"
+	for _, name := range strings.Split(strings.Trim(upath, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		current += name + "/"
+		crumbs = append(crumbs, breadcrumb{Name: name, URL: (&url.URL{Path: current}).String()})
+	}
+	return crumbs
+}
+
+// sortEntries sorts by name, size or date, ties are broken by name
+func sortEntries(entries []*listingEntry, column string, desc bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if desc {
+			a, b = b, a
+		}
+		switch column {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "date":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// listingHandler renders the folders without an index page with a template
+type listingHandler struct {
+	root       http.FileSystem
+	fileServer http.Handler
+	template   *template.Template
+	// visible filters the entries, eg. the ones rejected by the sandbox
+	visible func(upath string) bool
+}
+
+// ListingFileSystem returns a handler serving the filesystem with the listing template
+func ListingFileSystem(root http.FileSystem, tmpl *template.Template) http.Handler {
+	return newListingHandler(root, tmpl, nil)
+}
+
+func newListingHandler(root http.FileSystem, tmpl *template.Template, visible func(string) bool) *listingHandler {
+	return &listingHandler{
+		root:       root,
+		fileServer: http.FileServer(root),
+		template:   tmpl,
+		visible:    visible,
+	}
+}
+
+func (h *listingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upath := path.Clean("/" + r.URL.Path)
+	if !strings.HasSuffix(r.URL.Path, "/") || !isListing(h.root, upath) {
+		h.fileServer.ServeHTTP(w, r)
+		return
+	}
+
+	entries, err := readEntries(h.root, upath, 1, h.visible)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	query := r.URL.Query()
+	page := &listingPage{
+		Path:        r.URL.Path,
+		Breadcrumbs: newBreadcrumbs(upath),
+		Sort:        query.Get("sort"),
+		Order:       query.Get("order"),
+		Filter:      query.Get("q"),
+	}
+	if page.Sort != "size" && page.Sort != "date" {
+		page.Sort = "name"
+	}
+	if page.Order != "desc" {
+		page.Order = "asc"
+	}
+	filter := strings.ToLower(page.Filter)
+	for _, entry := range entries {
+		if strings.Contains(strings.ToLower(entry.Name), filter) {
+			page.Entries = append(page.Entries, entry)
+		}
+	}
+	sortEntries(page.Entries, page.Sort, page.Order == "desc")
+
+	// rendered before writing the headers to report template errors
+	var buf bytes.Buffer
+	if err := h.template.Execute(&buf, page); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// isListing returns true if the path is a directory without an index page
+func isListing(root http.FileSystem, upath string) bool {
+	file, err := root.Open(upath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	index, err := root.Open(path.Join(upath, "index.html"))
+	if err != nil {
+		return true
+	}
+	_ = index.Close()
+	return false
+}
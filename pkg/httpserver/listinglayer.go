@@ -0,0 +1,233 @@
+package httpserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListingLimit = 1000
+	maxListingDepth     = 16
+)
+
+// listingEntry is a file or folder of a json directory listing
+type listingEntry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Mode     string    `json:"mode"`
+	IsDir    bool      `json:"is_dir"`
+	MIMEType string    `json:"mime_type,omitempty"`
+	Checksum string    `json:"checksum,omitempty"`
+	// URL is the link relative to the listed folder
+	URL string `json:"-"`
+}
+
+// listing is the json document describing a folder
+type listing struct {
+	Path    string          `json:"path"`
+	Total   int             `json:"total"`
+	Offset  int             `json:"offset"`
+	Limit   int             `json:"limit"`
+	Entries []*listingEntry `json:"entries"`
+}
+
+// listingOptions are the query parameters of a json listing
+type listingOptions struct {
+	offset   int
+	limit    int
+	depth    int
+	checksum bool
+}
+
+// wantsJSONListing returns true if the client asked for a machine readable listing
+func wantsJSONListing(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// parseListingOptions reads offset, limit, depth and checksum from the query
+func parseListingOptions(r *http.Request) (listingOptions, error) {
+	options := listingOptions{limit: defaultListingLimit, depth: 1}
+	query := r.URL.Query()
+	intParams := map[string]*int{"offset": &options.offset, "limit": &options.limit, "depth": &options.depth}
+	for name, value := range intParams {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			return options, fmt.Errorf("invalid %s '%s'", name, raw)
+		}
+		*value = parsed
+	}
+	if options.depth < 1 || options.depth > maxListingDepth {
+		return options, fmt.Errorf("depth must be between 1 and %d", maxListingDepth)
+	}
+	switch query.Get("checksum") {
+	case "", "false", "0":
+	case "sha256", "true", "1":
+		options.checksum = true
+	default:
+		return options, fmt.Errorf("unsupported checksum '%s'", query.Get("checksum"))
+	}
+	return options, nil
+}
+
+// listinglayer serves json directory listings to the clients asking for them
+func (t *HTTPServer) listinglayer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wantsJSONListing(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		upath := path.Clean("/" + r.URL.Path)
+		dir, err := t.root.Open(upath)
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		info, err := dir.Stat()
+		_ = dir.Close()
+		if err != nil || !info.IsDir() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		options, err := parseListingOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		entries, err := readEntries(t.root, upath, options.depth, t.visibilityFilter())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Path < entries[j].Path
+		})
+
+		result := listing{Path: upath, Total: len(entries), Offset: options.offset, Limit: options.limit, Entries: []*listingEntry{}}
+		if options.offset < len(entries) {
+			end := len(entries)
+			if options.limit > 0 && options.offset+options.limit < end {
+				end = options.offset + options.limit
+			}
+			result.Entries = entries[options.offset:end]
+		}
+		if options.checksum {
+			// computed for the returned page only
+			for _, entry := range result.Entries {
+				if !entry.IsDir {
+					entry.Checksum, _ = t.fileChecksum(entry.Path)
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Vary", "Accept")
+		if r.Method == http.MethodHead {
+			return
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(result)
+	})
+}
+
+// readEntries returns the entries of the folder and of its subfolders up to depth,
+// the entries rejected by visible (if any) are skipped
+func readEntries(root http.FileSystem, upath string, depth int, visible func(string) bool) ([]*listingEntry, error) {
+	dir, err := root.Open(upath)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(-1)
+	_ = dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*listingEntry
+	for _, info := range infos {
+		entryPath := path.Join(upath, info.Name())
+		if visible != nil && !visible(entryPath) {
+			continue
+		}
+		entry := &listingEntry{
+			Name:    info.Name(),
+			Path:    entryPath,
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+			Mode:    info.Mode().String(),
+			IsDir:   info.IsDir(),
+			URL:     (&url.URL{Path: info.Name()}).String(),
+		}
+		if entry.IsDir {
+			entry.Path += "/"
+			entry.URL += "/"
+			entry.Size = 0
+		} else {
+			entry.MIMEType = mime.TypeByExtension(path.Ext(info.Name()))
+		}
+		entries = append(entries, entry)
+
+		if entry.IsDir && depth > 1 {
+			children, err := readEntries(root, entryPath, depth-1, visible)
+			if err != nil {
+				continue
+			}
+			entries = append(entries, children...)
+		}
+	}
+	return entries, nil
+}
+
+// visibilityFilter returns the filter hiding the entries rejected by the sandbox (eg. dotfiles or symlinks)
+func (t *HTTPServer) visibilityFilter() func(string) bool {
+	sandboxed := func(mount Mount) bool { return mount.Sandbox }
+	if !t.options.Sandbox && !slices.ContainsFunc(t.options.Mounts, sandboxed) {
+		return nil
+	}
+	return t.visible
+}
+
+func (t *HTTPServer) visible(upath string) bool {
+	file, err := t.root.Open(upath)
+	if err != nil {
+		return false
+	}
+	_ = file.Close()
+	return true
+}
+
+// fileChecksum returns the hex encoded sha256 of the file content
+func (t *HTTPServer) fileChecksum(upath string) (string, error) {
+	file, err := t.root.Open(upath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -1,23 +1,65 @@
 package httpserver
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
 	"time"
+
 	"github.com/projectdiscovery/gologger"
 )
 
 // Convenience globals
 var (
-	EnableUpload  bool
+	// Deprecated: has no effect, uploads are enabled per server with Options.EnableUpload.
+	EnableUpload bool
+	// Deprecated: has no effect, the verbose logs are enabled per server with Options.Verbose.
 	EnableVerbose bool
 )
 
+type logEntryContextKey struct{}
+
+// logEntry holds extra information filled by inner layers and printed by the log layer
+type logEntry struct {
+	Upstream        string
+	UpstreamLatency time.Duration
+}
+
+func (e *logEntry) String() string {
+	if e.Upstream == "" {
+		return ""
+	}
+	return fmt.Sprintf(" -> %s (%s)", e.Upstream, e.UpstreamLatency.Round(time.Microsecond))
+}
+
+// logEntryFromContext returns the log entry of the current request if any
+func logEntryFromContext(ctx context.Context) *logEntry {
+	entry, _ := ctx.Value(logEntryContextKey{}).(*logEntry)
+	return entry
+}
+
 func (t *HTTPServer) shouldDumpBody(bodysize int64) bool {
 	return t.options.MaxDumpBodySize > 0 && bodysize > t.options.MaxDumpBodySize
 }
 
+// requestProtocol returns the protocol version of the request, marking http/2 over cleartext
+func (t *HTTPServer) requestProtocol(r *http.Request) string {
+	switch {
+	case t.options.H2C && r.ProtoMajor == 2 && r.TLS == nil:
+		return r.Proto + " (h2c)"
+	case t.options.H2C && r.TLS == nil && r.Header.Get("HTTP2-Settings") != "" && strings.EqualFold(r.Header.Get("Upgrade"), "h2c"):
+		// the request upgrading the connection keeps its http/1.1 version but is answered over http/2
+		return "HTTP/2.0 (h2c upgrade)"
+	}
+	return r.Proto
+}
+
 func (t *HTTPServer) loglayer(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var fullRequest []byte
@@ -26,15 +68,17 @@ func (t *HTTPServer) loglayer(handler http.Handler) http.Handler {
 		} else {
 			fullRequest, _ = httputil.DumpRequest(r, true)
 		}
+		entry := &logEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), logEntryContextKey{}, entry))
 		lrw := newLoggingResponseWriter(w, t.options.MaxDumpBodySize)
 		handler.ServeHTTP(lrw, r)
 
-		if EnableVerbose {
+		if t.options.Verbose {
 			headers := new(bytes.Buffer)
 			lrw.Header().Write(headers) //nolint
-			gologger.Print().Msgf("\n[%s]\nRemote Address: %s\n%s\n%s %d %s\n%s\n%s\n", time.Now().Format("2006-01-02 15:04:05"), r.RemoteAddr, string(fullRequest), r.Proto, lrw.statusCode, http.StatusText(lrw.statusCode), headers.String(), string(lrw.Data))
+			gologger.Print().Msgf("\n[%s]\nRemote Address: %s%s\n%s\n%s %d %s\n%s\n%s\n", time.Now().Format("2006-01-02 15:04:05"), r.RemoteAddr, entry, string(fullRequest), t.requestProtocol(r), lrw.statusCode, http.StatusText(lrw.statusCode), headers.String(), string(lrw.Data))
 		} else {
-			gologger.Print().Msgf("[%s] %s \"%s %s %s\" %d %d", time.Now().Format("2006-01-02 15:04:05"), r.RemoteAddr, r.Method, r.URL, r.Proto, lrw.statusCode, lrw.Size)
+			gologger.Print().Msgf("[%s] %s \"%s %s %s\" %d %d%s", time.Now().Format("2006-01-02 15:04:05"), r.RemoteAddr, r.Method, r.URL, t.requestProtocol(r), lrw.statusCode, lrw.Size, entry)
 		}
 	})
 }
@@ -70,3 +114,28 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.statusCode = code
 	lrw.ResponseWriter.WriteHeader(code)
 }
+
+// Flush sends any buffered data to the client
+func (lrw *loggingResponseWriter) Flush() {
+	if flusher, ok := lrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection (eg. websocket upgrades)
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		lrw.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original response writer
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// access modes of the served files
+const (
+	ModeRead      = "read"
+	ModeWrite     = "write"
+	ModeReadWrite = "readwrite"
+	// ModeDropbox accepts new files only, without listing, downloading or overwriting them
+	ModeDropbox = "dropbox"
+)
+
+// Modes are the supported access modes
+var Modes = []string{ModeRead, ModeWrite, ModeReadWrite, ModeDropbox}
+
+// UploadEnabled returns whether uploads are enabled, the access mode takes precedence over the upload option
+func UploadEnabled(mode string, enableUpload bool) bool {
+	switch mode {
+	case ModeRead:
+		return false
+	case ModeWrite, ModeReadWrite, ModeDropbox:
+		return true
+	}
+	return enableUpload
+}
+
+// ValidateMode returns an error if the upload conflict policy can't be honoured by the access mode
+func ValidateMode(mode, uploadConflict string) error {
+	// dropbox uploads never replace an existing file, renaming them doesn't reveal it either
+	if mode == ModeDropbox && uploadConflict != "" && uploadConflict != ConflictReject && uploadConflict != ConflictRename {
+		return fmt.Errorf("upload conflict '%s' not supported in dropbox mode, must be one of %s, %s", uploadConflict, ConflictReject, ConflictRename)
+	}
+	return nil
+}
+
+// applyMode overrides the upload options with the access mode
+func applyMode(options *Options) error {
+	if err := ValidateMode(options.Mode, options.UploadConflict); err != nil {
+		return err
+	}
+	options.EnableUpload = UploadEnabled(options.Mode, options.EnableUpload)
+	if options.Mode == ModeDropbox && options.UploadConflict == "" {
+		// created exclusively, hidden or concurrent uploads can't overwrite a file either
+		options.UploadConflict = ConflictReject
+	}
+	return nil
+}
+
+// modelayer rejects the methods not allowed by the access mode, in front of the uploads and the file handler
+func (t *HTTPServer) modelayer(handler http.Handler) http.Handler {
+	var allowed []string
+	switch t.options.Mode {
+	case ModeRead:
+		allowed = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	case ModeWrite, ModeDropbox:
+		allowed = []string{http.MethodPut, http.MethodOptions}
+	case ModeReadWrite:
+		allowed = []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodOptions}
+	default:
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		switch {
+		case r.Method == http.MethodOptions:
+			// answered here as the file handler would serve the content
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case !slices.Contains(allowed, r.Method):
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,161 @@
+package httpserver
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mount serves a folder or an archive under a url prefix
+type Mount struct {
+	Prefix  string
+	Folder  string
+	Upload  bool
+	Sandbox bool
+}
+
+// mountFileSystem routes the paths under the mount prefixes to their filesystems,
+// the other ones to the root (if any) merged with the folders leading to the mount points
+type mountFileSystem struct {
+	root   http.FileSystem
+	mounts []Mount
+	fss    []http.FileSystem
+}
+
+// resolveMount returns the index of the longest mount prefix containing the path
+// and the path relative to the mounted folder, or -1 if there is none
+func resolveMount(mounts []Mount, upath string) (int, string) {
+	upath = path.Clean("/" + upath)
+	match := -1
+	for i, mount := range mounts {
+		if matchPrefix(mount.Prefix, upath) && (match == -1 || len(mount.Prefix) > len(mounts[match].Prefix)) {
+			match = i
+		}
+	}
+	if match == -1 {
+		return -1, upath
+	}
+	return match, path.Clean("/" + strings.TrimPrefix(upath, strings.TrimSuffix(mounts[match].Prefix, "/")))
+}
+
+func (mfs *mountFileSystem) Open(name string) (http.File, error) {
+	if i, rel := resolveMount(mfs.mounts, name); i != -1 {
+		return mfs.fss[i].Open(rel)
+	}
+
+	name = path.Clean("/" + name)
+	var (
+		f   http.File
+		err error = os.ErrNotExist
+	)
+	if mfs.root != nil {
+		f, err = mfs.root.Open(name)
+	}
+	entries := mfs.mountPoints(name)
+	if len(entries) == 0 {
+		return f, err
+	}
+
+	// the folders leading to the mount points exist even without a root
+	virtual := &virtualDir{info: mountInfo{name: path.Base(name)}, entries: entries}
+	if err != nil {
+		return virtual, nil
+	}
+	if info, err := f.Stat(); err != nil || !info.IsDir() {
+		_ = f.Close()
+		return virtual, nil
+	}
+	return &overlayDir{File: virtual, layers: []http.File{virtual, f}}, nil
+}
+
+// mountPoints returns the entries of the folder leading to the mount points
+func (mfs *mountFileSystem) mountPoints(dir string) []fs.FileInfo {
+	var entries []fs.FileInfo
+	seen := make(map[string]struct{})
+	for i, mount := range mfs.mounts {
+		prefix := strings.TrimSuffix(mount.Prefix, "/")
+		if !matchPrefix(dir, prefix) || prefix == dir {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(strings.TrimPrefix(prefix, dir), "/"), "/", 2)[0]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		info := mountInfo{name: name}
+		if path.Join(dir, name) == prefix {
+			// the mounted folder itself
+			if f, err := mfs.fss[i].Open("/"); err == nil {
+				if stat, err := f.Stat(); err == nil {
+					info.modTime = stat.ModTime()
+				}
+				_ = f.Close()
+			}
+		}
+		entries = append(entries, info)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// mountInfo describes the folders leading to the mount points
+type mountInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i mountInfo) Name() string       { return i.name }
+func (i mountInfo) Size() int64        { return 0 }
+func (i mountInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (i mountInfo) ModTime() time.Time { return i.modTime }
+func (i mountInfo) IsDir() bool        { return true }
+func (i mountInfo) Sys() interface{}   { return nil }
+
+// virtualDir is a folder existing only to reach the mount points
+type virtualDir struct {
+	info    mountInfo
+	entries []fs.FileInfo
+	offset  int
+}
+
+func (d *virtualDir) Close() error {
+	return nil
+}
+
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *virtualDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.offset = 0
+	}
+	return 0, nil
+}
+
+func (d *virtualDir) Readdir(count int) ([]fs.FileInfo, error) {
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+func (d *virtualDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
@@ -0,0 +1,110 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+)
+
+type connLimiterContextKey struct{}
+
+// connContext attaches a bandwidth limiter to each new connection
+func (t *HTTPServer) connContext(ctx context.Context, c net.Conn) context.Context {
+	if t.options.NetSim == nil {
+		return ctx
+	}
+	if limiter := t.options.NetSim.NewConnectionLimiter(); limiter != nil {
+		return context.WithValue(ctx, connLimiterContextKey{}, limiter)
+	}
+	return ctx
+}
+
+// netsimlayer simulates slow, lossy or unreliable links
+func (t *HTTPServer) netsimlayer(handler http.Handler) http.Handler {
+	simulator := t.options.NetSim
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		drop, reset := simulator.ShouldDrop(), simulator.ShouldReset()
+		if drop || reset {
+			action := "dropped"
+			if reset {
+				action = "reset"
+			}
+			gologger.Print().Msgf("[%s] %s \"%s %s %s\" connection %s", time.Now().Format("2006-01-02 15:04:05"), r.RemoteAddr, r.Method, r.URL, r.Proto, action)
+			abortConnection(w, reset)
+			return
+		}
+
+		limiter, _ := r.Context().Value(connLimiterContextKey{}).(*netsim.Limiter)
+		nw := &netsimResponseWriter{ResponseWriter: w}
+		nw.writer = simulator.NewWriter(w, limiter, -1)
+		handler.ServeHTTP(nw, r)
+	})
+}
+
+// abortConnection closes the underlying connection without sending a response
+func abortConnection(w http.ResponseWriter, reset bool) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		// eg. http2 streams can't be hijacked
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if reset {
+		_ = netsim.Reset(conn)
+		return
+	}
+	_ = conn.Close()
+}
+
+// netsimResponseWriter sends the response body through the simulated network
+type netsimResponseWriter struct {
+	http.ResponseWriter
+	writer *netsim.Writer
+	sized  bool
+}
+
+func (nw *netsimResponseWriter) WriteHeader(code int) {
+	nw.writer.Start()
+	nw.ResponseWriter.WriteHeader(code)
+}
+
+func (nw *netsimResponseWriter) Write(data []byte) (int, error) {
+	if !nw.sized {
+		nw.sized = true
+		if size, err := strconv.ParseInt(nw.Header().Get("Content-Length"), 10, 64); err == nil {
+			nw.writer.SetSize(size)
+		}
+	}
+	return nw.writer.Write(data)
+}
+
+// Flush sends any buffered data to the client
+func (nw *netsimResponseWriter) Flush() {
+	if flusher, ok := nw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection (eg. websocket upgrades)
+func (nw *netsimResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := nw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the original response writer
+func (nw *netsimResponseWriter) Unwrap() http.ResponseWriter {
+	return nw.ResponseWriter
+}
@@ -0,0 +1,146 @@
+package httpserver
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+)
+
+// OverlayFileSystem merges the layers, the files of the first ones shadow the files of the others
+type OverlayFileSystem struct {
+	Layers []http.FileSystem
+}
+
+// Open returns the file of the first layer containing the path, folders are merged with
+// the folders at the same path of the lower layers
+func (ofs OverlayFileSystem) Open(name string) (http.File, error) {
+	var (
+		dirs     []http.File
+		firstErr error
+	)
+	for i, layer := range ofs.Layers {
+		f, err := layer.Open(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if len(dirs) == 0 && ofs.shadowed(ofs.Layers[:i], name) {
+			_ = f.Close()
+			return nil, os.ErrNotExist
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !info.IsDir() {
+			if len(dirs) > 0 {
+				// shadowed by the folder of an upper layer
+				_ = f.Close()
+				continue
+			}
+			return f, nil
+		}
+		dirs = append(dirs, f)
+	}
+
+	switch len(dirs) {
+	case 0:
+		if firstErr == nil {
+			firstErr = os.ErrNotExist
+		}
+		return nil, firstErr
+	case 1:
+		return dirs[0], nil
+	}
+	return &overlayDir{File: dirs[0], layers: dirs}, nil
+}
+
+// shadowed returns true if a parent folder of the path is a file in one of the layers
+func (ofs OverlayFileSystem) shadowed(layers []http.FileSystem, name string) bool {
+	for dir := path.Dir(path.Clean("/" + name)); dir != "/"; dir = path.Dir(dir) {
+		for _, layer := range layers {
+			f, err := layer.Open(dir)
+			if err != nil {
+				continue
+			}
+			info, err := f.Stat()
+			_ = f.Close()
+			if err == nil && !info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlayDir is a folder present in multiple layers, its entries are merged by name
+type overlayDir struct {
+	http.File
+	layers  []http.File
+	entries []fs.FileInfo
+	read    bool
+	offset  int
+}
+
+func (d *overlayDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if !d.read {
+		seen := make(map[string]struct{})
+		for _, layer := range d.layers {
+			infos, err := layer.Readdir(-1)
+			if err != nil {
+				return nil, err
+			}
+			for _, info := range infos {
+				if _, ok := seen[info.Name()]; ok {
+					continue
+				}
+				seen[info.Name()] = struct{}{}
+				d.entries = append(d.entries, info)
+			}
+		}
+		sort.Slice(d.entries, func(i, j int) bool {
+			return d.entries[i].Name() < d.entries[j].Name()
+		})
+		d.read = true
+	}
+
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+func (d *overlayDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.offset = 0
+	}
+	return d.File.Seek(offset, whence)
+}
+
+func (d *overlayDir) Close() error {
+	var err error
+	for _, layer := range d.layers {
+		if closeErr := layer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
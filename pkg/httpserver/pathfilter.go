@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"bufio"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// PathFilter hides the paths matching the exclude patterns, unless they match an include one.
+// The patterns follow .gitignore: without a slash they match any element of the path
+// (eg. *.key or node_modules), otherwise they are anchored to the root (eg. /docs/private),
+// a leading **/ matches in any folder.
+type PathFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// LoadIgnoreFile reads a .gitignore-style file, the patterns starting with ! are included
+func LoadIgnoreFile(filename string) (include, exclude []string, err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "!"):
+			include = append(include, strings.TrimPrefix(line, "!"))
+		default:
+			exclude = append(exclude, line)
+		}
+	}
+	return include, exclude, scanner.Err()
+}
+
+// Hidden returns true if the path is excluded and not included
+func (f *PathFilter) Hidden(upath string) bool {
+	return matchPatterns(f.Exclude, upath) && !matchPatterns(f.Include, upath)
+}
+
+// matchPatterns returns true if a pattern matches the path or one of its parent folders
+func matchPatterns(patterns []string, upath string) bool {
+	upath = strings.Trim(path.Clean("/"+upath), "/")
+	if upath == "" {
+		return false
+	}
+	elements := strings.Split(upath, "/")
+	for _, pattern := range patterns {
+		if matchPattern(pattern, elements) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern string, elements []string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	anywhere := !strings.Contains(pattern, "/")
+	if strings.HasPrefix(pattern, "**/") {
+		pattern, anywhere = strings.TrimPrefix(pattern, "**/"), true
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	size := strings.Count(pattern, "/") + 1
+
+	for start := 0; start+size <= len(elements); start++ {
+		if matched, _ := path.Match(pattern, strings.Join(elements[start:start+size], "/")); matched {
+			return true
+		}
+		if !anywhere {
+			break
+		}
+	}
+	return false
+}
+
+// hidden returns true if the path is excluded by the options
+func (t *HTTPServer) hidden(upath string) bool {
+	return t.filter.Hidden(upath)
+}
+
+// filterFileSystem hides the paths rejected by the filter, as if they didn't exist
+type filterFileSystem struct {
+	fs     http.FileSystem
+	filter *PathFilter
+}
+
+func (ffs filterFileSystem) Open(name string) (http.File, error) {
+	if ffs.filter.Hidden(name) {
+		return nil, os.ErrNotExist
+	}
+	f, err := ffs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &filteredDir{File: f, dir: path.Clean("/" + name), filter: ffs.filter}, nil
+}
+
+// filteredDir skips the hidden entries of a folder
+type filteredDir struct {
+	http.File
+	dir    string
+	filter *PathFilter
+}
+
+func (d *filteredDir) Readdir(count int) ([]fs.FileInfo, error) {
+	for {
+		infos, err := d.File.Readdir(count)
+		visible := infos[:0]
+		for _, info := range infos {
+			if !d.filter.Hidden(path.Join(d.dir, info.Name())) {
+				visible = append(visible, info)
+			}
+		}
+		// with a count, an empty page is only returned at the end of the folder
+		if len(visible) > 0 || err != nil || count <= 0 {
+			return visible, err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// ProxyRule forwards requests matching a path prefix to an upstream server
+type ProxyRule struct {
+	Prefix   string
+	Upstream *url.URL
+}
+
+type proxyRoute struct {
+	ProxyRule
+	proxy *httputil.ReverseProxy
+}
+
+// matchPrefix reports whether the request path falls under the prefix, on a path segment boundary
+func matchPrefix(prefix, requestPath string) bool {
+	if prefix == "/" || requestPath == prefix {
+		return true
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// singleJoiningSlash joins two url paths making sure only one slash separates them
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+func (t *HTTPServer) newProxyRoute(rule ProxyRule) proxyRoute {
+	upstream := rule.Upstream
+	director := func(r *http.Request) {
+		requestPath := r.URL.Path
+		if t.options.ProxyStripPrefix {
+			requestPath = strings.TrimPrefix(requestPath, strings.TrimSuffix(rule.Prefix, "/"))
+			if requestPath == "" {
+				requestPath = "/"
+			}
+		}
+
+		r.Header.Set("X-Forwarded-Host", r.Host)
+		if r.TLS != nil {
+			r.Header.Set("X-Forwarded-Proto", "https")
+		} else {
+			r.Header.Set("X-Forwarded-Proto", "http")
+		}
+
+		r.URL.Scheme = upstream.Scheme
+		r.URL.Host = upstream.Host
+		r.URL.Path = singleJoiningSlash(upstream.Path, requestPath)
+		r.URL.RawPath = ""
+		if upstream.RawQuery == "" || r.URL.RawQuery == "" {
+			r.URL.RawQuery = upstream.RawQuery + r.URL.RawQuery
+		} else {
+			r.URL.RawQuery = upstream.RawQuery + "&" + r.URL.RawQuery
+		}
+		if !t.options.ProxyPreserveHost {
+			r.Host = upstream.Host
+		}
+
+		for _, header := range t.options.ProxyHeaders {
+			if strings.TrimSpace(header.Value) == "" {
+				r.Header.Del(header.Name)
+				continue
+			}
+			r.Header.Set(header.Name, header.Value)
+		}
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: director,
+		// flush immediately to support streaming responses (SSE, chunked)
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			gologger.Print().Msgf("proxy error for %s: %s\n", upstream, err)
+			w.WriteHeader(http.StatusBadGateway)
+		},
+	}
+	return proxyRoute{ProxyRule: rule, proxy: proxy}
+}
+
+// proxylayer forwards requests matching one of the configured prefixes to their upstream
+func (t *HTTPServer) proxylayer(handler http.Handler) http.Handler {
+	routes := make([]proxyRoute, 0, len(t.options.Proxies))
+	for _, rule := range t.options.Proxies {
+		routes = append(routes, t.newProxyRoute(rule))
+	}
+	// longest prefix wins
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].Prefix) > len(routes[j].Prefix)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range routes {
+			if !matchPrefix(route.Prefix, r.URL.Path) {
+				continue
+			}
+			start := time.Now()
+			route.proxy.ServeHTTP(w, r)
+			if entry := logEntryFromContext(r.Context()); entry != nil {
+				entry.Upstream = route.Upstream.String()
+				entry.UpstreamLatency = time.Since(start)
+			}
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
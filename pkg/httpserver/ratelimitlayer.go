@@ -0,0 +1,36 @@
+package httpserver
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// clientKey identifies the client by authenticated user or ip address
+func (t *HTTPServer) clientKey(r *http.Request) string {
+	if t.options.BasicAuthUsername != "" || t.options.BasicAuthPassword != "" {
+		if user, ok := t.authenticatedUser(r); ok {
+			return "user:" + user
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ratelimitlayer rejects clients exceeding the allowed request rate
+func (t *HTTPServer) ratelimitlayer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := t.options.RateLimiter.Allow(t.clientKey(r))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("Too Many Requests.\n")) //nolint
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -3,55 +3,70 @@ package httpserver
 import (
 	"errors"
 	"net/http"
-	"path/filepath"
+	"os"
+	"path"
+	"strings"
 )
 
-// SandboxFileSystem implements superbasic security checks
+// SandboxFileSystem confines the accesses to the root folder with os.Root (the kernel rejects
+// the paths and symlinks escaping it) and rejects dotfiles and symlinks
 type SandboxFileSystem struct {
-	fs         http.FileSystem
+	root       *os.Root
 	RootFolder string
+	// Include patterns allow dotfiles (eg. .well-known), see PathFilter
+	Include []string
 }
 
-// Open performs basic security checks before providing folder/file content
-func (sbfs SandboxFileSystem) Open(path string) (http.File, error) {
-	abspath, err := filepath.Abs(filepath.Join(sbfs.RootFolder, path))
-	if err != nil {
-		return nil, err
-	}
-
-	filename := filepath.Base(abspath)
-	// rejects names starting with a dot like .file
-	dotmatch, err := filepath.Match(".*", filename)
+// NewSandboxFileSystem opens the root folder, the filesystem must be closed after use
+func NewSandboxFileSystem(folder string) (SandboxFileSystem, error) {
+	root, err := os.OpenRoot(folder)
 	if err != nil {
-		return nil, err
-	} else if dotmatch {
-		return nil, errors.New("invalid file")
+		return SandboxFileSystem{}, err
 	}
+	return SandboxFileSystem{root: root, RootFolder: folder}, nil
+}
 
-	// reject symlinks
-	symlinkCheck, err := filepath.EvalSymlinks(abspath)
+// Open performs basic security checks before providing folder/file content
+func (sbfs SandboxFileSystem) Open(name string) (http.File, error) {
+	name, err := sandboxPath(name, sbfs.Include)
 	if err != nil {
 		return nil, err
 	}
-	if symlinkCheck != abspath {
-		return nil, errors.New("symlinks not allowed")
-	}
 
-	// check if the path is within the configured folder
-	if sbfs.RootFolder != abspath {
-		pattern := sbfs.RootFolder + string(filepath.Separator) + "*"
-		matched, err := filepath.Match(pattern, abspath)
+	// reject symlinks, including the ones of the parent folders
+	for current := name; current != "."; current = path.Dir(current) {
+		info, err := sbfs.root.Lstat(current)
 		if err != nil {
 			return nil, err
-		} else if !matched {
-			return nil, errors.New("invalid file")
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return nil, errors.New("symlinks not allowed")
 		}
 	}
 
-	f, err := sbfs.fs.Open(path)
-	if err != nil {
-		return nil, err
-	}
+	return sbfs.root.Open(name)
+}
+
+// Close releases the root folder
+func (sbfs SandboxFileSystem) Close() error {
+	return sbfs.root.Close()
+}
 
-	return f, nil
+// sandboxPath returns the path relative to the root, rejecting the names starting
+// with a dot like .file in any of its elements unless they are included
+func sandboxPath(name string, include []string) (string, error) {
+	if strings.ContainsRune(name, 0) || strings.Contains(name, "\\") {
+		return "", errors.New("invalid file")
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return ".", nil
+	}
+	elements := strings.Split(name, "/")
+	for i, element := range elements {
+		if strings.HasPrefix(element, ".") && !matchPatterns(include, strings.Join(elements[:i+1], "/")) {
+			return "", errors.New("invalid file")
+		}
+	}
+	return name, nil
 }
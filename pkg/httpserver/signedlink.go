@@ -0,0 +1,121 @@
+package httpserver
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tokenParam  = "token"
+	tokenCookie = "shs_token"
+)
+
+// newSigningKey returns a random key, signed links are valid for the lifetime of the process
+func newSigningKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// signToken signs the path a token grants access to together with its expiry
+func signToken(key []byte, upath, expiry string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(upath + "\n" + expiry)) //nolint
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenScopes returns the paths whose tokens grant access to the path: the path itself and its parent folders
+func tokenScopes(upath string) []string {
+	var scopes []string
+	for upath = path.Clean("/" + upath); upath != "/"; upath = path.Dir(upath) {
+		scopes = append(scopes, upath, upath+"/")
+	}
+	return append(scopes, "/")
+}
+
+// verifyToken checks the signature and the expiration of a token for the path,
+// returning the signed path it was issued for
+func verifyToken(key []byte, upath, token string) (string, time.Time, bool) {
+	expiry, signature, ok := strings.Cut(token, ".")
+	if !ok || len(key) == 0 {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return "", time.Time{}, false
+	}
+	for _, scope := range tokenScopes(upath) {
+		if hmac.Equal([]byte(signature), []byte(signToken(key, scope, expiry))) {
+			return scope, time.Unix(unix, 0), true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// SignURL returns the url with a token granting access without credentials until the ttl expires,
+// to the path of the url and, for a folder, to its content
+func (t *HTTPServer) SignURL(rawURL string, ttl time.Duration) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	scope := u.Path
+	if scope == "" {
+		scope = "/"
+	}
+	query := u.Query()
+	query.Set(tokenParam, expiry+"."+signToken(t.signingKey, scope, expiry))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// tokenAuthenticated accepts signed links, exchanging the token for a cookie scoped to the signed path so that
+// the following requests (eg. browsing the listing) don't need to carry it
+func (t *HTTPServer) tokenAuthenticated(w http.ResponseWriter, r *http.Request) (ok, redirected bool) {
+	upath := requestedPath(r)
+	if token := r.URL.Query().Get(tokenParam); token != "" {
+		scope, expiry, valid := verifyToken(t.signingKey, upath, token)
+		if !valid {
+			return false, false
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     tokenCookie,
+			Value:    token,
+			Path:     scope,
+			Expires:  expiry,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			target := *r.URL
+			query := target.Query()
+			query.Del(tokenParam)
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.RequestURI(), http.StatusFound)
+			return true, true
+		}
+		return true, false
+	}
+
+	// each signed path has its own cookie
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != tokenCookie {
+			continue
+		}
+		if _, _, valid := verifyToken(t.signingKey, upath, cookie.Value); valid {
+			return true, false
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,57 @@
+package httpserver
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// assetExtensions keep their 404 when missing, the other paths (eg. /users/john.doe) are client-side routes
+var assetExtensions = map[string]struct{}{
+	".js": {}, ".mjs": {}, ".css": {}, ".map": {}, ".json": {}, ".xml": {}, ".txt": {}, ".wasm": {},
+	".ico": {}, ".png": {}, ".jpg": {}, ".jpeg": {}, ".gif": {}, ".svg": {}, ".webp": {}, ".avif": {},
+	".woff": {}, ".woff2": {}, ".ttf": {}, ".otf": {}, ".eot": {},
+	".mp4": {}, ".webm": {}, ".mp3": {}, ".ogg": {}, ".wav": {}, ".pdf": {}, ".zip": {}, ".gz": {},
+}
+
+// acceptsHTML returns true if the client is navigating to a page rather than fetching an asset
+func acceptsHTML(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	_, asset := assetExtensions[strings.ToLower(path.Ext(r.URL.Path))]
+	return !asset
+}
+
+// spalayer serves the index file for client-side routes that don't exist on disk
+func (t *HTTPServer) spalayer(handler http.Handler) http.Handler {
+	index := "/" + strings.TrimPrefix(t.options.SPAIndex, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsHTML(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		file, err := t.root.Open(path.Clean("/" + r.URL.Path))
+		if err == nil {
+			_ = file.Close()
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		indexFile, err := t.root.Open(index)
+		if err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defer indexFile.Close()
+		info, err := indexFile.Stat()
+		if err != nil || info.IsDir() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), indexFile)
+	})
+}
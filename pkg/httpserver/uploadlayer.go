@@ -2,8 +2,11 @@ package httpserver
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -17,23 +20,26 @@ import (
 func (t *HTTPServer) uploadlayer(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Handles file write if enabled
-		if EnableUpload && r.Method == http.MethodPut {
+		target := t.uploadTarget(r.URL.Path)
+		if r.Method == http.MethodPut && target.mounted && !target.Upload {
+			http.Error(w, "read-only mount", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPut && target.Upload && t.hidden(r.URL.Path) {
+			http.Error(w, "excluded path", http.StatusForbidden)
+			return
+		}
+		if target.Upload && r.Method == http.MethodPut {
 			// sandbox - calcolate absolute path
-			if t.options.Sandbox {
-				absPath, err := filepath.Abs(filepath.Join(t.options.Folder, r.URL.Path))
+			if target.Sandbox {
+				absPath, err := filepath.Abs(filepath.Join(target.Folder, target.path))
 				if err != nil {
 					gologger.Print().Msgf("%s\n", err)
 					w.WriteHeader(http.StatusBadRequest)
 					return
 				}
-				// check if the path is within the configured folder
-				pattern := t.options.Folder + string(filepath.Separator) + "*"
-				matched, err := filepath.Match(pattern, absPath)
-				if err != nil {
-					gologger.Print().Msgf("%s\n", err)
-					w.WriteHeader(http.StatusBadRequest)
-					return
-				} else if !matched {
+				// check if the path is within the upload folder
+				if !strings.HasPrefix(absPath, target.Folder+string(filepath.Separator)) {
 					gologger.Print().Msg("pointing to unauthorized directory")
 					w.WriteHeader(http.StatusBadRequest)
 					return
@@ -44,7 +50,7 @@ func (t *HTTPServer) uploadlayer(handler http.Handler) http.Handler {
 				data []byte
 				err  error
 			)
-			if t.options.Sandbox {
+			if target.Sandbox {
 				maxFileSize := unit.ToMb(t.options.MaxFileSize)
 				// check header content length
 				if r.ContentLength > maxFileSize {
@@ -62,14 +68,28 @@ func (t *HTTPServer) uploadlayer(handler http.Handler) http.Handler {
 				return
 			}
 
-			sanitizedPath := filepath.FromSlash(path.Clean("/" + strings.Trim(r.URL.Path, "/")))
+			sanitizedPath := filepath.FromSlash(path.Clean("/" + strings.Trim(target.path, "/")))
 
-			err = handleUpload(t.options.Folder, sanitizedPath, data)
-			if err != nil {
+			if !target.mounted {
+				if err := t.mirrorUploadDir(sanitizedPath); err != nil {
+					gologger.Print().Msgf("%s\n", err)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+			stored, err := handleUpload(target.Folder, sanitizedPath, data, t.options.UploadConflict)
+			if errors.Is(err, errUploadConflict) {
+				http.Error(w, "file already exists", http.StatusConflict)
+				return
+			} else if err != nil {
 				gologger.Print().Msgf("%s\n", err)
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			} else {
+				if stored != filepath.Base(sanitizedPath) {
+					// renamed to avoid the conflict
+					w.Header().Set("Location", (&url.URL{Path: path.Join(path.Dir(r.URL.Path), stored)}).String())
+				}
 				w.WriteHeader(http.StatusCreated)
 				return
 			}
@@ -79,21 +99,157 @@ func (t *HTTPServer) uploadlayer(handler http.Handler) http.Handler {
 	})
 }
 
-func handleUpload(base, file string, data []byte) error {
+// uploadDestination is the folder receiving an upload
+type uploadDestination struct {
+	Mount
+	// path is relative to the folder
+	path    string
+	mounted bool
+}
+
+// uploadTarget returns the mount containing the path, or the upload folder of the root
+func (t *HTTPServer) uploadTarget(upath string) uploadDestination {
+	if i, rel := resolveMount(t.options.Mounts, upath); i != -1 {
+		return uploadDestination{Mount: t.options.Mounts[i], path: rel, mounted: true}
+	}
+	root := Mount{Prefix: "/", Folder: t.options.UploadFolder, Upload: t.options.EnableUpload && t.options.Folder != "", Sandbox: t.options.Sandbox}
+	return uploadDestination{Mount: root, path: upath}
+}
+
+// upload conflict policies, applied when the file already exists
+const (
+	ConflictOverwrite = "overwrite"
+	ConflictReject    = "reject"
+	ConflictRename    = "rename"
+	// ConflictVersion keeps the previous versions in a hidden folder
+	ConflictVersion = "version"
+)
+
+// ConflictPolicies are the supported upload conflict policies
+var ConflictPolicies = []string{ConflictOverwrite, ConflictReject, ConflictRename, ConflictVersion}
+
+// maxRenameAttempts bounds the suffixes tried by the rename policy
+const maxRenameAttempts = 10000
+
+var errUploadConflict = errors.New("file already exists")
+
+// handleUpload writes the file through os.Root, the kernel rejects the paths escaping
+// the base folder (including via symlinks). It returns the name of the stored file,
+// which differs with the rename policy.
+func handleUpload(base, file string, data []byte, conflict string) (string, error) {
 	// rejects all paths containing a non exhaustive list of invalid characters - This is only a best effort as the tool is meant for development
 	if strings.ContainsAny(file, "\\`\"':") {
-		return errors.New("invalid character")
+		return "", errors.New("invalid character")
+	}
+
+	root, err := os.OpenRoot(base)
+	if err != nil {
+		return "", err
 	}
+	defer root.Close()
 
-	untrustedPath := filepath.Clean(filepath.Join(base, file))
-	if !strings.HasPrefix(untrustedPath, filepath.Clean(base)) {
-		return errors.New("invalid path")
+	name := rootRelative(file)
+	if _, err := root.Stat(filepath.Dir(name)); os.IsNotExist(err) {
+		return "", errors.New("invalid path")
 	}
-	trustedPath := untrustedPath
 
-	if _, err := os.Stat(filepath.Dir(trustedPath)); os.IsNotExist(err) {
-		return errors.New("invalid path")
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	switch conflict {
+	case ConflictReject:
+		flag = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	case ConflictRename:
+		return writeRenamed(root, name, data)
+	case ConflictVersion:
+		if err := saveVersion(root, name); err != nil {
+			return "", err
+		}
+	}
+	f, err := root.OpenFile(name, flag, 0655)
+	if errors.Is(err, fs.ErrExist) {
+		return "", errUploadConflict
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return "", err
 	}
+	return filepath.Base(name), f.Close()
+}
 
-	return os.WriteFile(trustedPath, data, 0655)
+// writeRenamed writes the file with the first free name among 'file.txt', 'file (1).txt', ...
+func writeRenamed(root *os.Root, name string, data []byte) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 0; i < maxRenameAttempts; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+		// created exclusively to not overwrite a concurrent upload
+		f, err := root.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0655)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(data); err != nil {
+			_ = f.Close()
+			return "", err
+		}
+		return filepath.Base(candidate), f.Close()
+	}
+	return "", errUploadConflict
+}
+
+// rootRelative returns the cleaned path relative to an os.Root
+func rootRelative(file string) string {
+	name := strings.TrimLeft(filepath.Clean(string(filepath.Separator)+file), string(filepath.Separator))
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// mirrorUploadDir creates in the upload folder the parent folder of the file if it only
+// exists in the other overlaid folders
+func (t *HTTPServer) mirrorUploadDir(file string) error {
+	if len(t.options.Overlay) == 0 {
+		return nil
+	}
+	root, err := os.OpenRoot(t.options.UploadFolder)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	dir := filepath.Dir(rootRelative(file))
+	if _, err := root.Stat(dir); !os.IsNotExist(err) {
+		return nil
+	}
+	f, err := t.root.Open(filepath.ToSlash(dir))
+	if err != nil {
+		// handleUpload rejects the missing folders
+		return nil
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	return mkdirAllRoot(root, dir)
+}
+
+// mkdirAllRoot creates the folder and its parents in the root, os.Root has no MkdirAll before go 1.25
+func mkdirAllRoot(root *os.Root, dir string) error {
+	var current string
+	for _, element := range strings.Split(dir, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		if err := root.Mkdir(current, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
 }
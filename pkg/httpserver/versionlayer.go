@@ -0,0 +1,208 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/gologger"
+)
+
+// versionsFolder keeps the previous versions of the uploaded files, at the root of the upload folder
+const versionsFolder = ".shs-versions"
+
+// versionIDFormat sorts the versions chronologically
+const versionIDFormat = "20060102T150405.000000000Z"
+
+// fileVersion is a previous version of a file
+type fileVersion struct {
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// versionlayer lists (?versions), downloads (?version=id) and restores (PUT ?restore=id)
+// the previous versions of the uploaded files
+func (t *HTTPServer) versionlayer(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		isRead := r.Method == http.MethodGet || r.Method == http.MethodHead
+		var action string
+		switch {
+		case isRead && query.Has("versions"):
+			action = "list"
+		case isRead && query.Get("version") != "":
+			action = "download"
+		case r.Method == http.MethodPut && query.Get("restore") != "":
+			action = "restore"
+		default:
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		target := t.uploadTarget(r.URL.Path)
+		_, err := sandboxPath(target.path, t.options.Include)
+		if target.Folder == "" || t.hidden(r.URL.Path) || target.Sandbox && err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if action == "restore" && !target.Upload {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		root, err := os.OpenRoot(target.Folder)
+		if err != nil {
+			gologger.Print().Msgf("%s\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer root.Close()
+		name := rootRelative(filepath.FromSlash(target.path))
+
+		switch action {
+		case "list":
+			versions, err := listVersions(root, name)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			_ = encoder.Encode(versions)
+		case "download":
+			version, err := openVersion(root, name, query.Get("version"))
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			defer version.Close()
+			info, err := version.Stat()
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			http.ServeContent(w, r, filepath.Base(name), info.ModTime(), version)
+		case "restore":
+			err := restoreVersion(root, name, query.Get("restore"))
+			if errors.Is(err, os.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			} else if err != nil {
+				gologger.Print().Msgf("%s\n", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+// versionsDir returns the folder keeping the versions of the file
+func versionsDir(name string) string {
+	return filepath.Join(versionsFolder, name)
+}
+
+// saveVersion copies the current content of the file (if any) as a new version
+func saveVersion(root *os.Root, name string) error {
+	current, err := root.Open(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer current.Close()
+	info, err := current.Stat()
+	if err != nil || info.IsDir() {
+		// the upload fails anyway on folders
+		return err
+	}
+
+	if err := mkdirAllRoot(root, versionsDir(name)); err != nil {
+		return err
+	}
+	id := time.Now().UTC().Format(versionIDFormat)
+	version, err := root.OpenFile(filepath.Join(versionsDir(name), id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(version, current); err != nil {
+		_ = version.Close()
+		return err
+	}
+	return version.Close()
+}
+
+// listVersions returns the versions of the file, the latest first
+func listVersions(root *os.Root, name string) ([]fileVersion, error) {
+	dir, err := root.Open(versionsDir(name))
+	if os.IsNotExist(err) {
+		return []fileVersion{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	infos, err := dir.Readdir(-1)
+	_ = dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []fileVersion{}
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !validVersionID(info.Name()) {
+			continue
+		}
+		versions = append(versions, fileVersion{ID: info.Name(), Size: info.Size(), ModTime: info.ModTime().UTC()})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ID > versions[j].ID
+	})
+	return versions, nil
+}
+
+// openVersion opens a version of the file
+func openVersion(root *os.Root, name, id string) (*os.File, error) {
+	if !validVersionID(id) {
+		return nil, os.ErrNotExist
+	}
+	return root.Open(filepath.Join(versionsDir(name), id))
+}
+
+// restoreVersion replaces the file with the version, the current content is kept as a new version
+func restoreVersion(root *os.Root, name, id string) error {
+	version, err := openVersion(root, name, id)
+	if err != nil {
+		return err
+	}
+	defer version.Close()
+
+	if err := saveVersion(root, name); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0655)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, version); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// validVersionID rejects the ids which are not timestamps, eg. paths
+func validVersionID(id string) bool {
+	if strings.ContainsAny(id, `/\`) {
+		return false
+	}
+	_, err := time.Parse(versionIDFormat, id)
+	return err == nil
+}
@@ -0,0 +1,2 @@
+// Package netsim contains helpers simulating degraded network conditions
+package netsim
@@ -0,0 +1,52 @@
+package netsim
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket limiting the number of bytes per second
+type Limiter struct {
+	mux    sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter allowing bytesPerSecond with a one second burst
+func NewLimiter(bytesPerSecond int64) *Limiter {
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		burst:  int(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// Burst returns the max number of bytes that can be consumed at once
+func (l *Limiter) Burst() int {
+	return l.burst
+}
+
+// WaitN blocks until n bytes can be sent
+func (l *Limiter) WaitN(n int) {
+	l.mux.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+	// take the tokens right away and sleep for the debt
+	l.tokens -= float64(n)
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mux.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,179 @@
+package netsim
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Options of the simulated network conditions
+type Options struct {
+	ConnectionRate   int64 // bytes per second for each connection
+	GlobalRate       int64 // bytes per second shared by all the connections
+	Latency          time.Duration
+	DropProbability  float64
+	ResetProbability float64
+	StallProbability float64
+	StallDuration    time.Duration
+}
+
+// Simulator applies the configured network conditions
+type Simulator struct {
+	options *Options
+	global  *Limiter
+
+	mux  sync.Mutex
+	rand *rand.Rand
+}
+
+// New simulator instance with the specified options
+func New(options *Options) *Simulator {
+	s := &Simulator{
+		options: options,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if options.GlobalRate > 0 {
+		s.global = NewLimiter(options.GlobalRate)
+	}
+	return s
+}
+
+// Enabled returns true if at least one condition is configured
+func (s *Simulator) Enabled() bool {
+	o := s.options
+	return o.ConnectionRate > 0 || o.GlobalRate > 0 || o.Latency > 0 ||
+		o.DropProbability > 0 || o.ResetProbability > 0 || o.StallProbability > 0
+}
+
+func (s *Simulator) chance(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.rand.Float64() < probability
+}
+
+// ShouldDrop returns true if the connection should be closed without answering
+func (s *Simulator) ShouldDrop() bool {
+	return s.chance(s.options.DropProbability)
+}
+
+// ShouldReset returns true if the connection should be reset without answering
+func (s *Simulator) ShouldReset() bool {
+	return s.chance(s.options.ResetProbability)
+}
+
+// NewConnectionLimiter returns the bandwidth limiter of a new connection, if any
+func (s *Simulator) NewConnectionLimiter() *Limiter {
+	if s.options.ConnectionRate <= 0 {
+		return nil
+	}
+	return NewLimiter(s.options.ConnectionRate)
+}
+
+// NewWriter wraps a response of the given size (-1 if unknown) sent over a connection
+func (s *Simulator) NewWriter(w io.Writer, conn *Limiter, size int64) *Writer {
+	writer := &Writer{w: w, simulator: s, conn: conn, size: size, stallAt: -1}
+	if s.chance(s.options.StallProbability) {
+		writer.stall = true
+	}
+	return writer
+}
+
+// Reset closes the connection sending a TCP RST instead of a FIN
+func Reset(conn net.Conn) error {
+	inner := conn
+	// unwrap tls and other wrapping connections
+	for {
+		wrapper, ok := inner.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		inner = wrapper.NetConn()
+	}
+	if tcpConn, ok := inner.(*net.TCPConn); ok {
+		if err := tcpConn.SetLinger(0); err != nil {
+			return err
+		}
+	}
+	return conn.Close()
+}
+
+// Writer delays, throttles and stalls the data written to the underlying writer
+type Writer struct {
+	w         io.Writer
+	simulator *Simulator
+	conn      *Limiter
+	size      int64
+	started   bool
+	stall     bool
+	stallAt   int64
+	written   int64
+}
+
+// SetSize sets the size of the response once it is known, before the first write
+func (w *Writer) SetSize(size int64) {
+	w.size = size
+}
+
+// Start waits for the configured latency before the first byte, only once
+func (w *Writer) Start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	if latency := w.simulator.options.Latency; latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// Write the data applying the bandwidth limits
+func (w *Writer) Write(data []byte) (int, error) {
+	w.Start()
+	if w.stall && w.stallAt < 0 {
+		// stall in the middle of the body
+		if w.size > 0 {
+			w.stallAt = w.size / 2
+		} else {
+			w.stallAt = int64(len(data) / 2)
+		}
+	}
+
+	total := 0
+	for len(data) > 0 {
+		chunk := len(data)
+		for _, limiter := range []*Limiter{w.conn, w.simulator.global} {
+			if limiter != nil && limiter.Burst() > 0 && chunk > limiter.Burst() {
+				chunk = limiter.Burst()
+			}
+		}
+		if w.stall && w.written < w.stallAt && w.written+int64(chunk) > w.stallAt {
+			chunk = int(w.stallAt - w.written)
+		}
+		for _, limiter := range []*Limiter{w.conn, w.simulator.global} {
+			if limiter != nil {
+				limiter.WaitN(chunk)
+			}
+		}
+
+		n, err := w.w.Write(data[:chunk])
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		data = data[chunk:]
+
+		if w.stall && w.written == w.stallAt {
+			w.stall = false
+			if flusher, ok := w.w.(interface{ Flush() }); ok {
+				flusher.Flush()
+			}
+			time.Sleep(w.simulator.options.StallDuration)
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnLimiter caps the number of concurrent connections, globally and per ip
+type ConnLimiter struct {
+	mux      sync.Mutex
+	maxTotal int
+	maxPerIP int
+	total    int
+	perIP    map[string]int
+}
+
+// NewConnLimiter returns a new connection limiter, zero values mean unlimited
+func NewConnLimiter(maxTotal, maxPerIP int) *ConnLimiter {
+	return &ConnLimiter{maxTotal: maxTotal, maxPerIP: maxPerIP, perIP: make(map[string]int)}
+}
+
+// Acquire a slot for a connection from the address
+func (c *ConnLimiter) Acquire(addr net.Addr) bool {
+	ip := hostOf(addr)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.maxTotal > 0 && c.total >= c.maxTotal {
+		return false
+	}
+	if c.maxPerIP > 0 && c.perIP[ip] >= c.maxPerIP {
+		return false
+	}
+	c.total++
+	c.perIP[ip]++
+	return true
+}
+
+// Release the slot of a closed connection
+func (c *ConnLimiter) Release(addr net.Addr) {
+	ip := hostOf(addr)
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.total--
+	if c.perIP[ip]--; c.perIP[ip] <= 0 {
+		delete(c.perIP, ip)
+	}
+}
+
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// NewListener returns a listener closing the connections exceeding the limits
+func NewListener(listener net.Listener, limiter *ConnLimiter) net.Listener {
+	return &limitListener{Listener: listener, limiter: limiter}
+}
+
+type limitListener struct {
+	net.Listener
+	limiter *ConnLimiter
+}
+
+// Accept waits for the next connection within the limits
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.limiter.Acquire(conn.RemoteAddr()) {
+			_ = conn.Close()
+			continue
+		}
+		return &limitConn{Conn: conn, limiter: l.limiter}, nil
+	}
+}
+
+type limitConn struct {
+	net.Conn
+	limiter *ConnLimiter
+	once    sync.Once
+}
+
+// Close the connection and release its slot
+func (c *limitConn) Close() error {
+	c.once.Do(func() { c.limiter.Release(c.RemoteAddr()) })
+	return c.Conn.Close()
+}
+
+// NetConn returns the underlying connection
+func (c *limitConn) NetConn() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,2 @@
+// Package ratelimit contains request rate and connection limiting helpers
+package ratelimit
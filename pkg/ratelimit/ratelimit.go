@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// idleTimeout after which full buckets are discarded
+const idleTimeout = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a token bucket rate limiter keyed by client
+type Limiter struct {
+	mux     sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	cleaned time.Time
+}
+
+// New limiter allowing rate requests per second for each key, with the specified burst
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		cleaned: time.Now(),
+	}
+}
+
+// Allow consumes a token for the key, otherwise it returns how long to wait for the next one
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// cleanup discards the buckets which refilled completely
+func (l *Limiter) cleanup(now time.Time) {
+	if now.Sub(l.cleaned) < idleTimeout {
+		return
+	}
+	l.cleaned = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds()*l.rate+b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
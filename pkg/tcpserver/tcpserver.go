@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+	"github.com/projectdiscovery/simplehttpserver/pkg/ratelimit"
 	"github.com/projectdiscovery/sslcert"
 	"gopkg.in/yaml.v2"
 )
@@ -25,6 +27,8 @@ type Options struct {
 	Domain      string
 	rules       []Rule
 	Verbose     bool
+	NetSim      *netsim.Simulator
+	ConnLimiter *ratelimit.ConnLimiter
 }
 
 // CallBackFunc handles what is send back to the client, based on the incomming question
@@ -32,19 +36,25 @@ type CallBackFunc func(ctx context.Context, question []byte) (answer []byte, err
 
 // TCPServer instance
 type TCPServer struct {
-	options  *Options
-	listener net.Listener
+	options   *Options
+	listeners []net.Listener
 
 	// Callbacks to retrieve information about the system
 	HandleMessageFnc CallBackFunc
 
 	mux   sync.RWMutex
 	rules []Rule
+
+	// active connections, tracked to drain them on shutdown
+	connsMux sync.Mutex
+	conns    map[net.Conn]struct{}
+	closing  bool
+	wg       sync.WaitGroup
 }
 
 // New tcp server instance with specified options
 func New(options *Options) (*TCPServer, error) {
-	srv := &TCPServer{options: options}
+	srv := &TCPServer{options: options, conns: make(map[net.Conn]struct{})}
 	srv.HandleMessageFnc = srv.BuildResponseWithContext
 	srv.rules = options.rules
 	return srv, nil
@@ -61,12 +71,16 @@ func (t *TCPServer) AddRule(rule Rule) error {
 
 // ListenAndServe requests
 func (t *TCPServer) ListenAndServe() error {
-	listener, err := net.Listen("tcp4", t.options.Listen)
+	listener, err := net.Listen("tcp", t.options.Listen)
 	if err != nil {
 		return err
 	}
-	t.listener = listener
-	return t.run()
+	return t.Serve(listener)
+}
+
+// Serve requests on the listener
+func (t *TCPServer) Serve(listener net.Listener) error {
+	return t.run(listener)
 }
 
 func (t *TCPServer) handleConnection(conn net.Conn, callback CallBackFunc) error {
@@ -75,13 +89,22 @@ func (t *TCPServer) handleConnection(conn net.Conn, callback CallBackFunc) error
 	// Create Context
 	ctx := context.WithValue(context.Background(), Addr, conn.RemoteAddr())
 
+	var limiter *netsim.Limiter
+	if t.options.NetSim != nil {
+		limiter = t.options.NetSim.NewConnectionLimiter()
+	}
+
 	buf := make([]byte, 4096)
 	for {
-		if err := conn.SetReadDeadline(time.Now().Add(readTimeout * time.Second)); err != nil {
-			gologger.Info().Msgf("%s\n", err)
+		if !t.armReadDeadline(conn) {
+			return nil
 		}
 		n, err := conn.Read(buf)
 		if err != nil {
+			if t.isClosing() {
+				// woken up by the shutdown
+				return nil
+			}
 			return err
 		}
 
@@ -93,7 +116,19 @@ func (t *TCPServer) handleConnection(conn net.Conn, callback CallBackFunc) error
 			return err
 		}
 
-		if _, err := conn.Write(resp); err != nil {
+		if t.options.NetSim != nil && t.options.NetSim.Enabled() {
+			if t.options.NetSim.ShouldReset() {
+				gologger.Info().Msgf("Resetting connection from %s\n", conn.RemoteAddr())
+				return netsim.Reset(conn)
+			}
+			if t.options.NetSim.ShouldDrop() {
+				gologger.Info().Msgf("Dropping connection from %s\n", conn.RemoteAddr())
+				return nil
+			}
+			if _, err := t.options.NetSim.NewWriter(conn, limiter, int64(len(resp))).Write(resp); err != nil {
+				gologger.Info().Msgf("%s\n", err)
+			}
+		} else if _, err := conn.Write(resp); err != nil {
 			gologger.Info().Msgf("%s\n", err)
 		}
 
@@ -103,44 +138,169 @@ func (t *TCPServer) handleConnection(conn net.Conn, callback CallBackFunc) error
 
 // ListenAndServeTLS requests over tls
 func (t *TCPServer) ListenAndServeTLS() error {
-	var tlsConfig *tls.Config
+	listener, err := net.Listen("tcp", t.options.Listen)
+	if err != nil {
+		return err
+	}
+	return t.ServeTLS(listener)
+}
+
+// ServeTLS requests over tls on the listener
+func (t *TCPServer) ServeTLS(listener net.Listener) error {
+	tlsConfig, err := t.makeTLSConfig()
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+	return t.run(tls.NewListener(listener, tlsConfig))
+}
+
+func (t *TCPServer) makeTLSConfig() (*tls.Config, error) {
 	if t.options.Certificate != "" && t.options.Key != "" {
 		cert, err := tls.LoadX509KeyPair(t.options.Certificate, t.options.Key)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
-	} else {
-		tlsOptions := sslcert.DefaultOptions
-		tlsOptions.Host = t.options.Domain
-		cfg, err := sslcert.NewTLSConfig(tlsOptions)
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+	tlsOptions := sslcert.DefaultOptions
+	tlsOptions.Host = t.options.Domain
+	return sslcert.NewTLSConfig(tlsOptions)
+}
+
+func (t *TCPServer) run(listener net.Listener) error {
+	t.connsMux.Lock()
+	if t.closing {
+		t.connsMux.Unlock()
+		return listener.Close()
+	}
+	t.listeners = append(t.listeners, listener)
+	t.connsMux.Unlock()
+
+	for {
+		c, err := listener.Accept()
 		if err != nil {
+			if t.isClosing() {
+				return nil
+			}
 			return err
 		}
-		tlsConfig = cfg
+		if t.options.ConnLimiter != nil && !t.options.ConnLimiter.Acquire(c.RemoteAddr()) {
+			gologger.Info().Msgf("Too many connections, rejecting %s\n", c.RemoteAddr())
+			_ = c.Close()
+			continue
+		}
+		if !t.trackConn(c) {
+			_ = c.Close()
+			if t.options.ConnLimiter != nil {
+				t.options.ConnLimiter.Release(c.RemoteAddr())
+			}
+			continue
+		}
+		go func() {
+			defer t.untrackConn(c)
+			if t.options.ConnLimiter != nil {
+				defer t.options.ConnLimiter.Release(c.RemoteAddr())
+			}
+			t.handleConnection(c, t.HandleMessageFnc) //nolint
+		}()
 	}
+}
 
-	listener, err := tls.Listen("tcp", t.options.Listen, tlsConfig)
-	if err != nil {
-		return err
+// trackConn registers an active connection unless the server is closing
+func (t *TCPServer) trackConn(c net.Conn) bool {
+	t.connsMux.Lock()
+	defer t.connsMux.Unlock()
+	if t.closing {
+		return false
 	}
-	t.listener = listener
-	return t.run()
+	t.conns[c] = struct{}{}
+	t.wg.Add(1)
+	return true
 }
 
-func (t *TCPServer) run() error {
-	for {
-		c, err := t.listener.Accept()
-		if err != nil {
-			return err
+func (t *TCPServer) untrackConn(c net.Conn) {
+	t.connsMux.Lock()
+	delete(t.conns, c)
+	t.connsMux.Unlock()
+	t.wg.Done()
+}
+
+// armReadDeadline sets the timeout of the next question unless the server is closing,
+// under the lock of Shutdown so that its wake-up deadline can't be overwritten
+func (t *TCPServer) armReadDeadline(conn net.Conn) bool {
+	t.connsMux.Lock()
+	defer t.connsMux.Unlock()
+	if t.closing {
+		return false
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(readTimeout * time.Second)); err != nil {
+		gologger.Info().Msgf("%s\n", err)
+	}
+	return true
+}
+
+func (t *TCPServer) isClosing() bool {
+	t.connsMux.Lock()
+	defer t.connsMux.Unlock()
+	return t.closing
+}
+
+// stopListening marks the server as closing and closes the listeners, if any
+func (t *TCPServer) stopListening() error {
+	t.connsMux.Lock()
+	t.closing = true
+	listeners := t.listeners
+	t.connsMux.Unlock()
+
+	var firstErr error
+	for _, listener := range listeners {
+		if err := listener.Close(); err != nil && firstErr == nil {
+			firstErr = err
 		}
-		go t.handleConnection(c, t.HandleMessageFnc) //nolint
+	}
+	return firstErr
+}
+
+// Shutdown stops accepting connections and waits for the active ones to complete,
+// closing them when the context expires
+func (t *TCPServer) Shutdown(ctx context.Context) error {
+	err := t.stopListening()
+
+	// wake up the connections waiting for a question
+	t.connsMux.Lock()
+	for c := range t.conns {
+		_ = c.SetReadDeadline(time.Now())
+	}
+	t.connsMux.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		t.closeConns()
+		return ctx.Err()
 	}
 }
 
 // Close the service
 func (t *TCPServer) Close() error {
-	return t.listener.Close()
+	err := t.stopListening()
+	t.closeConns()
+	return err
+}
+
+func (t *TCPServer) closeConns() {
+	t.connsMux.Lock()
+	defer t.connsMux.Unlock()
+	for c := range t.conns {
+		_ = c.Close()
+	}
 }
 
 // LoadTemplate from yaml
@@ -4,3 +4,8 @@ package unit
 func ToMb(n int) int64 {
 	return int64(n) * 1024 * 1024
 }
+
+// KbToBytes converts kilobytes to bytes
+func KbToBytes(n int) int64 {
+	return int64(n) * 1024
+}
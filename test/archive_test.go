@@ -0,0 +1,78 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestDirectoryZipDownload(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "fixtures")
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "a", "sub/b.txt": "bb", ".hidden": "h"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "a.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Sandbox: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/?download=zip", nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("want a zip archive, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, file := range archive.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		got[file.Name] = string(content)
+	}
+	// dotfiles and symlinks are excluded by the sandbox
+	want := map[string]string{"fixtures/": "", "fixtures/a.txt": "a", "fixtures/sub/": "", "fixtures/sub/b.txt": "bb"}
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s: want '%s', got '%s'", name, content, got[name])
+		}
+	}
+}
+
+func TestDirectoryDownloadMaxSize(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.bin"), make([]byte, 2048), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server, err := httpserver.New(&httpserver.Options{Folder: root, MaxArchiveSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/?download=tar.gz", nil))
+	if w.Code != 413 {
+		t.Errorf("want 413, got %d", w.Code)
+	}
+}
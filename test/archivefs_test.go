@@ -0,0 +1,119 @@
+package test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/archivefs"
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+var archiveFixture = map[string]string{
+	"index.txt":      "root",
+	"sub/file.txt":   "0123456789",
+	"sub/deep/x.bin": "deep",
+}
+
+func writeTarFixture(t *testing.T, name string) {
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tw := tar.NewWriter(file)
+	for entryName, content := range archiveFixture {
+		if err := tw.WriteHeader(&tar.Header{Name: entryName, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeZipFixture(t *testing.T, name string) {
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for entryName, content := range archiveFixture {
+		w, err := zw.Create(entryName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveFileSystems(t *testing.T) {
+	dir := t.TempDir()
+	writeTarFixture(t, filepath.Join(dir, "fixture.tar"))
+	writeZipFixture(t, filepath.Join(dir, "fixture.zip"))
+
+	for _, name := range []string{"fixture.tar", "fixture.zip"} {
+		fsys, closer, err := archivefs.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fstest.TestFS(fsys, "index.txt", "sub/file.txt", "sub/deep/x.bin"); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+
+		// seeking backwards and forwards in the entries
+		file, err := fsys.Open("sub/file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		seeker := file.(io.ReadSeeker)
+		buf := make([]byte, 3)
+		for _, offset := range []int64{5, 1, 7} {
+			if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.ReadFull(seeker, buf); err != nil {
+				t.Fatal(err)
+			}
+			if want := archiveFixture["sub/file.txt"][offset : offset+3]; string(buf) != want {
+				t.Errorf("%s: at %d want '%s', got '%s'", name, offset, want, buf)
+			}
+		}
+		file.Close()
+		closer.Close()
+	}
+}
+
+func TestServeFS(t *testing.T) {
+	fsys := fstest.MapFS{"assets/app.js": {Data: []byte("console.log(1)")}}
+	server, err := httpserver.NewFS(fsys, &httpserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/assets/app.js", nil)
+	r.Header.Set("Range", "bytes=8-")
+	server.ServeHTTP(w, r)
+	if w.Code != 206 || w.Body.String() != "log(1)" {
+		t.Errorf("want partial content 'log(1)', got %d '%s'", w.Code, w.Body.String())
+	}
+
+	if _, err := httpserver.NewFS(fsys, &httpserver.Options{EnableUpload: true}); err == nil {
+		t.Error("want an error for uploads to a read-only filesystem")
+	}
+}
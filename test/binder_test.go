@@ -0,0 +1,108 @@
+package test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/binder"
+)
+
+func TestListenInRangeSkipsBusyPorts(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	port := busy.Addr().(*net.TCPAddr).Port
+
+	listener, err := binder.ListenInRange("127.0.0.1:0", port, port+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if got := listener.Addr().(*net.TCPAddr).Port; got <= port {
+		t.Errorf("want a port after the busy %d, got %d", port, got)
+	}
+
+	if _, err := binder.ListenInRange("127.0.0.1:0", port, port); err == nil {
+		t.Error("want an error when the whole range is busy")
+	}
+}
+
+func TestListenRandomPort(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	listener, err := binder.ListenRandomPort(busy.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	addr := listener.Addr().(*net.TCPAddr)
+	if !addr.IP.Equal(net.IPv4(127, 0, 0, 1)) || addr.Port == busy.Addr().(*net.TCPAddr).Port {
+		t.Errorf("want another port on 127.0.0.1, got %s", addr)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	start, end, err := binder.ParsePortRange("8000-8100")
+	if err != nil || start != 8000 || end != 8100 {
+		t.Errorf("want 8000-8100, got %d-%d (%v)", start, end, err)
+	}
+	for _, value := range []string{"8000", "8100-8000", "0-10", "a-b", "1-70000"} {
+		if _, _, err := binder.ParsePortRange(value); err == nil {
+			t.Errorf("want an error for '%s'", value)
+		}
+	}
+}
+
+func TestListenUnixRemovesStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket files are not removed on windows")
+	}
+	path := filepath.Join(t.TempDir(), "shs.sock")
+
+	// a socket file left behind by a crashed process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := binder.ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatalf("stale socket should be replaced: %s", err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("want mode 0600, got %s", info.Mode().Perm())
+	}
+
+	if _, err := binder.ListenUnix(path, 0600); err == nil {
+		t.Error("want an error when the socket is in use")
+	}
+}
+
+func TestGetRandomListenAddress(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		address, err := binder.GetRandomListenAddress(net.JoinHostPort(host, "8000"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotHost, port, _ := net.SplitHostPort(address)
+		if gotHost != host || port == "0" || port == "8000" {
+			t.Errorf("want a free port on %s, got %s", host, address)
+		}
+	}
+}
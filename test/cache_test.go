@@ -0,0 +1,111 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestCacheRuleMatches(t *testing.T) {
+	for _, test := range []struct {
+		match, path string
+		want        bool
+	}{
+		{"*.html", "/index.html", true},
+		{"*.html", "/docs/guide/index.html", true},
+		{"*.html", "/index.htm", false},
+		{"assets/*", "/assets/app.123.js", true},
+		{"/assets/*", "/assets/app.123.js", true},
+		{"assets/*", "/static/assets/app.js", false},
+		{"assets/*", "/assets/js/app.js", false},
+		{"[", "/index.html", false},
+	} {
+		rule := httpserver.CacheRule{Match: test.match, Value: "no-store"}
+		if got := rule.Matches(test.path); got != test.want {
+			t.Errorf("%s %s: want %t, got %t", test.match, test.path, test.want, got)
+		}
+	}
+}
+
+func TestCacheControlAndETag(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"page.html": "v1", "assets/app.js": "app", "assets/app.js.gz": "gzipped"})
+	server, err := httpserver.New(&httpserver.Options{
+		Folder:        root,
+		ETag:          true,
+		Precompressed: true,
+		CacheRules: []httpserver.CacheRule{
+			{Match: "assets/*", Value: "public, max-age=31536000, immutable"},
+			{Match: "*.html", Value: "no-store"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(server, "GET", "/page.html", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("want an etag and no-store, got %d %v", w.Code, w.Header())
+	}
+	if w := serve(server, "GET", "/page.html", map[string]string{"If-None-Match": etag}); w.Code != 304 {
+		t.Errorf("want 304, got %d", w.Code)
+	}
+	if w := serve(server, "HEAD", "/page.html", nil); w.Header().Get("ETag") != etag {
+		t.Errorf("head: want %s, got %s", etag, w.Header().Get("ETag"))
+	}
+
+	// a modified file gets a new etag, even with the same size
+	if err := os.WriteFile(filepath.Join(root, "page.html"), []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "page.html"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	w = serve(server, "GET", "/page.html", map[string]string{"If-None-Match": etag})
+	if w.Code != 200 || w.Body.String() != "v2" || w.Header().Get("ETag") == etag {
+		t.Errorf("modified: want 200 'v2' with a new etag, got %d '%s' %s", w.Code, w.Body.String(), w.Header().Get("ETag"))
+	}
+
+	// the precompressed sibling follows the rules of the requested file with its own validator
+	identity := serve(server, "GET", "/assets/app.js", nil)
+	compressed := serve(server, "GET", "/assets/app.js", map[string]string{"Accept-Encoding": "gzip"})
+	if compressed.Body.String() != "gzipped" || compressed.Header().Get("Cache-Control") != identity.Header().Get("Cache-Control") {
+		t.Errorf("precompressed: want the rule of app.js, got '%s' %v", compressed.Body.String(), compressed.Header())
+	}
+	if compressed.Header().Get("ETag") == "" || compressed.Header().Get("ETag") == identity.Header().Get("ETag") {
+		t.Errorf("precompressed: want a distinct etag, got %s and %s", identity.Header().Get("ETag"), compressed.Header().Get("ETag"))
+	}
+}
+
+func TestETagOnlyOnServedFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"report.txt": "report"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, ETag: true, BasicAuthUsername: "user", BasicAuthPassword: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(server, "GET", "/report.txt", nil); w.Code != 401 || w.Header().Get("ETag") != "" {
+		t.Errorf("auth: want 401 without etag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	server, err = httpserver.New(&httpserver.Options{Folder: root, ETag: true, Mode: httpserver.ModeDropbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(server, "GET", "/report.txt", nil); w.Code != 405 || w.Header().Get("ETag") != "" {
+		t.Errorf("dropbox: want 405 without etag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	server, err = httpserver.New(&httpserver.Options{Folder: root, ETag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(server, "GET", "/report.txt", map[string]string{"Range": "bytes=10-20"}); w.Code != 416 || w.Header().Get("ETag") != "" {
+		t.Errorf("range: want 416 without etag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+}
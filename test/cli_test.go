@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	binaryDir   string
+	binaryPath  string
+	binaryErr   error
+	binaryBuild sync.Once
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if binaryDir != "" {
+		_ = os.RemoveAll(binaryDir)
+	}
+	os.Exit(code)
+}
+
+// buildBinary builds the command once for the tests running it
+func buildBinary(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the binary")
+	}
+	binaryBuild.Do(func() {
+		if binaryDir, binaryErr = os.MkdirTemp("", "simplehttpserver"); binaryErr != nil {
+			return
+		}
+		binaryPath = filepath.Join(binaryDir, "simplehttpserver")
+		output, err := exec.Command("go", "build", "-o", binaryPath, "../cmd/simplehttpserver").CombinedOutput()
+		if err != nil {
+			binaryErr = fmt.Errorf("%w: %s", err, output)
+		}
+	})
+	if binaryErr != nil {
+		t.Fatal(binaryErr)
+	}
+	return binaryPath
+}
+
+// lockedBuffer collects the output of a running process
+type lockedBuffer struct {
+	mux    sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buffer.Write(data)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buffer.String()
+}
+
+type boundListener struct {
+	Protocol string `json:"protocol"`
+	Network  string `json:"network"`
+	Address  string `json:"address"`
+}
+
+// serverProcess is the command running in the background
+type serverProcess struct {
+	cmd       *exec.Cmd
+	output    *lockedBuffer
+	listeners []boundListener
+	done      chan error
+}
+
+// startServer runs the command until its listeners are bound, it is killed at the end of the test
+func startServer(t *testing.T, env []string, args ...string) *serverProcess {
+	t.Helper()
+	addressFile := filepath.Join(t.TempDir(), "addresses.json")
+	cmd := exec.Command(buildBinary(t), append([]string{"-address-file", addressFile}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	output := &lockedBuffer{}
+	cmd.Stdout, cmd.Stderr = output, output
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	process := &serverProcess{cmd: cmd, output: output, done: make(chan error, 1)}
+	go func() {
+		process.done <- cmd.Wait()
+	}()
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+	})
+
+	deadline := time.After(20 * time.Second)
+	for {
+		if data, err := os.ReadFile(addressFile); err == nil {
+			var addresses struct {
+				Listeners []boundListener `json:"listeners"`
+			}
+			if err := json.Unmarshal(data, &addresses); err != nil {
+				t.Fatal(err)
+			}
+			process.listeners = addresses.Listeners
+			return process
+		}
+		select {
+		case err := <-process.done:
+			t.Fatalf("exited before listening: %v\n%s", err, output)
+		case <-deadline:
+			t.Fatalf("not listening after 20s\n%s", output)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// stop interrupts the command and returns its exit error
+func (p *serverProcess) stop(t *testing.T) error {
+	t.Helper()
+	if err := p.cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-p.done:
+		return err
+	case <-time.After(20 * time.Second):
+		t.Fatalf("still running 20s after the interrupt\n%s", p.output)
+	}
+	return nil
+}
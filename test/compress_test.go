@@ -0,0 +1,133 @@
+package test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func serve(server *httpserver.HTTPServer, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	root := t.TempDir()
+	large := strings.Repeat("compress me ", 200)
+	writeFiles(t, root, map[string]string{"large.txt": large, "small.txt": "tiny", "image.png": large})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Compress: true, CompressMinSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for accept, want := range map[string]string{
+		"gzip":                    "gzip",
+		"gzip, br":                "br",
+		"gzip;q=1, br;q=0.5":      "gzip",
+		"zstd, br;q=0":            "zstd",
+		"*":                       "br",
+		"identity":                "",
+		"br;q=0, zstd;q=0, *;q=0": "",
+	} {
+		w := serve(server, "GET", "/large.txt", map[string]string{"Accept-Encoding": accept})
+		if got := w.Header().Get("Content-Encoding"); got != want {
+			t.Errorf("%s: want encoding '%s', got '%s'", accept, want, got)
+		}
+	}
+
+	w := serve(server, "GET", "/large.txt", map[string]string{"Accept-Encoding": "gzip"})
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(reader); string(body) != large {
+		t.Errorf("want the decompressed body, got %d bytes", len(body))
+	}
+	if w.Header().Get("Content-Length") != "" || !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+		t.Errorf("want no length and a vary header, got %v", w.Header())
+	}
+
+	// below the minimum size or outside of the compressed types
+	for _, path := range []string{"/small.txt", "/image.png"} {
+		w := serve(server, "GET", path, map[string]string{"Accept-Encoding": "gzip"})
+		if w.Code != 200 || w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: want an identity response, got %d %s", path, w.Code, w.Header().Get("Content-Encoding"))
+		}
+	}
+
+	// ranges address the identity content
+	w = serve(server, "GET", "/large.txt", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-7"})
+	if w.Code != 206 || w.Header().Get("Content-Encoding") != "" || w.Body.String() != "compress" {
+		t.Errorf("range: want 206 'compress', got %d %s '%s'", w.Code, w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+}
+
+func TestCompressWeakETag(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"large.txt": strings.Repeat("compress me ", 200)})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Compress: true, ETag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity := serve(server, "GET", "/large.txt", nil).Header().Get("ETag")
+	compressed := serve(server, "GET", "/large.txt", map[string]string{"Accept-Encoding": "gzip"}).Header().Get("ETag")
+	if identity == "" || strings.HasPrefix(identity, "W/") || compressed != "W/"+identity {
+		t.Errorf("want a strong identity etag and its weak version, got '%s' '%s'", identity, compressed)
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"app.js": "plain", "app.js.gz": "gzipped", "app.js.br": "brotli", ".env": "secret", ".env.gz": "secret"})
+	options := &httpserver.Options{Folder: root, Precompressed: true, Exclude: []string{".env"}}
+	server, err := httpserver.New(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for accept, want := range map[string]string{"gzip": "gzipped", "gzip, br": "brotli", "br;q=0.1, gzip": "gzipped", "": "plain"} {
+		w := serve(server, "GET", "/app.js", map[string]string{"Accept-Encoding": accept})
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: want '%s', got %d '%s'", accept, want, w.Code, w.Body.String())
+		}
+		if accept != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+			t.Errorf("%s: want the type of the original file, got %s", accept, w.Header().Get("Content-Type"))
+		}
+	}
+	w := serve(server, "GET", "/.env", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != 404 {
+		t.Errorf("hidden: want 404, got %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestPrecompressedThroughInnerLayers(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"secret.js": "plain", "secret.js.gz": "gzipped"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Precompressed: true, BasicAuthUsername: "user", BasicAuthPassword: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := serve(server, "GET", "/secret.js", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != 401 || w.Header().Get("Content-Encoding") != "" || strings.Contains(w.Body.String(), "gzipped") {
+		t.Errorf("auth: want 401, got %d %s '%s'", w.Code, w.Header().Get("Content-Encoding"), w.Body.String())
+	}
+
+	server, err = httpserver.New(&httpserver.Options{Folder: root, Precompressed: true, Mode: httpserver.ModeDropbox})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(server, "GET", "/secret.js", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != 405 || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("dropbox: want 405, got %d %s", w.Code, w.Header().Get("Content-Encoding"))
+	}
+}
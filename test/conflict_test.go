@@ -0,0 +1,120 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func newConflictServer(t *testing.T, conflict string) (*httpserver.HTTPServer, string) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"report.txt": "v1"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, EnableUpload: true, UploadConflict: conflict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, root
+}
+
+func put(server *httpserver.HTTPServer, path, content string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("PUT", path, strings.NewReader(content)))
+	return w
+}
+
+func TestUploadConflict(t *testing.T) {
+	server, root := newConflictServer(t, httpserver.ConflictReject)
+	if w := put(server, "/report.txt", "v2"); w.Code != 409 {
+		t.Errorf("reject: want 409, got %d", w.Code)
+	}
+	if w := put(server, "/new.txt", "new"); w.Code != 201 {
+		t.Errorf("reject: want 201 for a new file, got %d", w.Code)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "report.txt")); string(content) != "v1" {
+		t.Errorf("reject: want 'v1', got '%s'", content)
+	}
+
+	server, root = newConflictServer(t, httpserver.ConflictRename)
+	for _, want := range []string{"/report%20%281%29.txt", "/report%20%282%29.txt"} {
+		w := put(server, "/report.txt", "v2")
+		if w.Code != 201 || w.Header().Get("Location") != want {
+			t.Errorf("rename: want 201 with location %s, got %d %s", want, w.Code, w.Header().Get("Location"))
+		}
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "report (2).txt")); string(content) != "v2" {
+		t.Errorf("rename: want 'v2', got '%s'", content)
+	}
+}
+
+func TestUploadVersions(t *testing.T) {
+	server, root := newConflictServer(t, httpserver.ConflictVersion)
+	for _, content := range []string{"v2", "v3"} {
+		if w := put(server, "/report.txt", content); w.Code != 201 {
+			t.Fatalf("want 201, got %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/report.txt?versions", nil))
+	var versions []struct {
+		ID   string `json:"id"`
+		Size int64  `json:"size"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &versions); err != nil {
+		t.Fatalf("%s: %s", err, w.Body.String())
+	}
+	if len(versions) != 2 {
+		t.Fatalf("want 2 versions, got %+v", versions)
+	}
+
+	// the latest version comes first
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/report.txt?version="+versions[1].ID, nil))
+	if w.Code != 200 || w.Body.String() != "v1" {
+		t.Errorf("want the first version, got %d '%s'", w.Code, w.Body.String())
+	}
+	if w := put(server, "/report.txt?restore="+versions[1].ID, ""); w.Code != 204 {
+		t.Errorf("restore: want 204, got %d", w.Code)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "report.txt")); string(content) != "v1" {
+		t.Errorf("restore: want 'v1', got '%s'", content)
+	}
+
+	for method, path := range map[string]string{"GET": "/report.txt?version=../../report.txt", "PUT": "/report.txt?restore=..%2F..%2Freport.txt", "HEAD": "/.shs-versions/"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if w.Code != 404 {
+			t.Errorf("%s %s: want 404, got %d", method, path, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/?format=json", nil))
+	if strings.Contains(w.Body.String(), ".shs-versions") {
+		t.Errorf("want the versions hidden from the listing, got %s", w.Body.String())
+	}
+}
+
+func TestUploadVersionsKeepOptions(t *testing.T) {
+	root := t.TempDir()
+	exclude := make([]string, 1, 2)
+	exclude[0] = "*.key"
+	options := &httpserver.Options{Folder: root, EnableUpload: true, UploadConflict: httpserver.ConflictVersion, Exclude: exclude}
+	for i := 0; i < 2; i++ {
+		server, err := httpserver.New(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the versions folder stays hidden from the uploads
+		if w := put(server, "/.shs-versions/report.txt", "forged"); w.Code == 201 {
+			t.Errorf("want the upload in the versions folder rejected, got %d", w.Code)
+		}
+	}
+	if len(options.Exclude) != 1 || exclude[:2][1] != "" {
+		t.Errorf("want the excluded patterns of the options untouched, got %v %v", options.Exclude, exclude[:2])
+	}
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+	"golang.org/x/net/http2"
+)
+
+// h2cClient speaks http/2 over cleartext with prior knowledge
+func h2cClient() *http.Client {
+	return &http.Client{Timeout: 5 * time.Second, Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}}
+}
+
+// h2cUpgrade sends an http/1.1 request asking to upgrade to h2c and returns the status line
+func h2cUpgrade(t *testing.T, address, path string) string {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQAAP__\r\n\r\n", path, address)
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(status, "HTTP/1.1 101") {
+		// completes the upgrade so the server answers the request over http/2
+		_, _ = io.WriteString(conn, http2.ClientPreface)
+		_ = http2.NewFramer(conn, nil).WriteSettings()
+		time.Sleep(100 * time.Millisecond)
+	}
+	return strings.TrimSpace(status)
+}
+
+// serveH2C serves the folder on a random port and returns its address
+func serveH2C(t *testing.T, enabled bool) string {
+	t.Helper()
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"hello.txt": "hello"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, H2C: enabled})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener) //nolint
+	return listener.Addr().String()
+}
+
+func TestH2CNegotiation(t *testing.T) {
+	address := serveH2C(t, true)
+	get := func(client *http.Client) *http.Response {
+		t.Helper()
+		var lastErr error
+		// the server starts in the background
+		for i := 0; i < 50; i++ {
+			response, err := client.Get("http://" + address + "/hello.txt")
+			if err == nil {
+				body, _ := io.ReadAll(response.Body)
+				_ = response.Body.Close()
+				if string(body) != "hello" {
+					t.Errorf("%s: want 'hello', got '%s'", response.Proto, body)
+				}
+				return response
+			}
+			lastErr = err
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal(lastErr)
+		return nil
+	}
+
+	if response := get(h2cClient()); response.ProtoMajor != 2 {
+		t.Errorf("prior knowledge: want http/2, got %s", response.Proto)
+	}
+	// plain http/1.1 clients keep working on the same listener
+	if response := get(&http.Client{Timeout: 5 * time.Second}); response.ProtoMajor != 1 {
+		t.Errorf("http/1.1: want http/1.1, got %s", response.Proto)
+	}
+	if status := h2cUpgrade(t, address, "/hello.txt"); !strings.HasPrefix(status, "HTTP/1.1 101") {
+		t.Errorf("upgrade: want 101 Switching Protocols, got '%s'", status)
+	}
+
+	// without the option the connection stays on http/1.1
+	address = serveH2C(t, false)
+	get(&http.Client{Timeout: 5 * time.Second})
+	if response, err := h2cClient().Get("http://" + address + "/hello.txt"); err == nil {
+		_ = response.Body.Close()
+		t.Errorf("prior knowledge without h2c: want an error, got %s", response.Proto)
+	}
+	if status := h2cUpgrade(t, address, "/hello.txt"); !strings.HasPrefix(status, "HTTP/1.1 200") {
+		t.Errorf("upgrade without h2c: want 200 over http/1.1, got '%s'", status)
+	}
+}
+
+func TestH2CLogging(t *testing.T) {
+	server := startServer(t, nil, "-path", t.TempDir(), "-listen", "127.0.0.1:0", "-h2c")
+	address := server.listeners[0].Address
+
+	response, err := h2cClient().Get("http://" + address + "/prior")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	waitOutput(t, server, `"GET /prior HTTP/2.0 (h2c)" 404`)
+
+	h2cUpgrade(t, address, "/upgrade")
+	waitOutput(t, server, `"GET /upgrade HTTP/2.0 (h2c upgrade)" 404`)
+
+	response, err = http.Get("http://" + address + "/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+	waitOutput(t, server, `"GET /plain HTTP/1.1" 404`)
+
+	// the preface sent to a server without h2c is not logged as an h2c request
+	server = startServer(t, nil, "-path", t.TempDir(), "-listen", "127.0.0.1:0")
+	if response, err := h2cClient().Get("http://" + server.listeners[0].Address + "/prior"); err == nil {
+		_ = response.Body.Close()
+	}
+	waitOutput(t, server, `"PRI /%2A HTTP/2.0" 404`)
+	if strings.Contains(server.output.String(), "(h2c)") {
+		t.Errorf("want no h2c request without the option, got %s", server.output)
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3AltSvc(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"hello.txt": "hello"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, HTTP3: true, CertificateDomain: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// http/3 shares the port of the first tls listener, the second one is tcp only
+	shared, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := shared.Addr().(*net.TCPAddr).Port
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		_ = shared.Close()
+		t.Skipf("udp port %d not available: %s", port, err)
+	}
+	tcpOnly, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(shared)       //nolint
+	go server.ServeTLS(tcpOnly)      //nolint
+	go server.ServeHTTP3(packetConn) //nolint
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true} //nolint
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	get := func(client *http.Client, address string) *http.Response {
+		t.Helper()
+		var lastErr error
+		// the servers start in the background
+		for i := 0; i < 50; i++ {
+			response, err := client.Get("https://" + address + "/hello.txt")
+			if err == nil {
+				body, _ := io.ReadAll(response.Body)
+				_ = response.Body.Close()
+				if string(body) != "hello" {
+					t.Errorf("%s: want 'hello', got '%s'", address, body)
+				}
+				return response
+			}
+			lastErr = err
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("%s: %s", address, lastErr)
+		return nil
+	}
+
+	want := `h3=":` + strconv.Itoa(port) + `"; ma=86400`
+	if got := get(client, shared.Addr().String()).Header.Get("Alt-Svc"); got != want {
+		t.Errorf("shared port: want '%s', got '%s'", want, got)
+	}
+	if got := get(client, tcpOnly.Addr().String()).Header.Get("Alt-Svc"); got != "" {
+		t.Errorf("tcp only: want no Alt-Svc, got '%s'", got)
+	}
+
+	// the advertised endpoint answers over http/3, without advertising itself again
+	h3Transport := &http3.Transport{TLSClientConfig: tlsConfig}
+	defer h3Transport.Close()
+	response := get(&http.Client{Timeout: 5 * time.Second, Transport: h3Transport}, shared.Addr().String())
+	if response.ProtoMajor != 3 || response.Header.Get("Alt-Svc") != "" {
+		t.Errorf("http/3: want an http/3 response without Alt-Svc, got %s %s", response.Proto, response.Header.Get("Alt-Svc"))
+	}
+}
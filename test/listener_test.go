@@ -0,0 +1,70 @@
+package test
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMultipleListeners(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"hello.txt": "hello"})
+	rules := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(rules, []byte("rules:\n  - match: ping\n    name: ping\n    response: pong\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := startServer(t, nil, "-path", root, "-rules", rules,
+		"-listen", "http://127.0.0.1:0", "-listen", "https://127.0.0.1:0", "-listen", "tcp://127.0.0.1:0")
+	if len(server.listeners) != 3 {
+		t.Fatalf("want 3 listeners, got %+v", server.listeners)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}} //nolint
+	for _, listener := range server.listeners {
+		switch listener.Protocol {
+		case "http", "https":
+			response, err := client.Get(listener.Protocol + "://" + listener.Address + "/hello.txt")
+			if err != nil {
+				t.Errorf("%s: %s", listener.Protocol, err)
+				continue
+			}
+			body, _ := io.ReadAll(response.Body)
+			_ = response.Body.Close()
+			if string(body) != "hello" {
+				t.Errorf("%s: want 'hello', got '%s'", listener.Protocol, body)
+			}
+		case "tcp":
+			conn, err := net.DialTimeout("tcp", listener.Address, 5*time.Second)
+			if err != nil {
+				t.Errorf("tcp: %s", err)
+				continue
+			}
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			_, _ = conn.Write([]byte("ping"))
+			answer := make([]byte, 4)
+			_, err = io.ReadFull(conn, answer)
+			_ = conn.Close()
+			if err != nil || string(answer) != "pong" {
+				t.Errorf("tcp: want 'pong', got '%s' %v", answer, err)
+			}
+		default:
+			t.Errorf("unexpected listener %+v", listener)
+		}
+	}
+
+	// all the listeners stop on the interrupt, with a successful exit
+	if err := server.stop(t); err != nil {
+		t.Fatalf("want a clean exit, got %s\n%s", err, server.output)
+	}
+	for _, listener := range server.listeners {
+		if conn, err := net.DialTimeout("tcp", listener.Address, time.Second); err == nil {
+			_ = conn.Close()
+			t.Errorf("%s: still listening on %s", listener.Protocol, listener.Address)
+		}
+	}
+}
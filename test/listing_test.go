@@ -0,0 +1,107 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+type listingResponse struct {
+	Total   int `json:"total"`
+	Entries []struct {
+		Name     string `json:"name"`
+		Path     string `json:"path"`
+		Size     int64  `json:"size"`
+		IsDir    bool   `json:"is_dir"`
+		Checksum string `json:"checksum"`
+	} `json:"entries"`
+}
+
+func getListing(t *testing.T, server *httpserver.HTTPServer, target string) listingResponse {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("want a json listing, got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	var listing listingResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	return listing
+}
+
+func TestJSONListing(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"a.txt": "a", "b.txt": "bb", "sub/c.txt": "ccc"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := httpserver.New(&httpserver.Options{Folder: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listing := getListing(t, server, "/?format=json&checksum=sha256")
+	if listing.Total != 3 || len(listing.Entries) != 3 {
+		t.Fatalf("want 3 entries, got %+v", listing)
+	}
+	first := listing.Entries[0]
+	if first.Name != "a.txt" || first.Size != 1 || first.IsDir {
+		t.Errorf("unexpected first entry %+v", first)
+	}
+	// sha256 of "a"
+	if first.Checksum != "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb" {
+		t.Errorf("unexpected checksum %s", first.Checksum)
+	}
+
+	listing = getListing(t, server, "/?format=json&depth=2&offset=2&limit=1")
+	if listing.Total != 4 || len(listing.Entries) != 1 || listing.Entries[0].Path != "/sub/" {
+		t.Errorf("want the page [/sub/] of 4 entries, got %+v", listing)
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/?format=json&depth=100", nil))
+	if w.Code != 400 {
+		t.Errorf("want 400 for an invalid depth, got %d", w.Code)
+	}
+}
+
+func TestListingTemplateSortAndFilter(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"a.log": "aaa", "b.txt": "b", "c.log": "cc"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmplPath := filepath.Join(t.TempDir(), "listing.html")
+	if err := os.WriteFile(tmplPath, []byte(`{{range .Entries}}{{.Name}} {{end}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := httpserver.LoadListingTemplate(tmplPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := httpserver.ListingFileSystem(http.Dir(root), tmpl)
+
+	for target, want := range map[string]string{
+		"/":                            "a.log b.txt c.log ",
+		"/?sort=size&order=desc":       "a.log c.log b.txt ",
+		"/?q=LOG&sort=name&order=desc": "c.log a.log ",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if got := w.Body.String(); got != want {
+			t.Errorf("%s: want '%s', got '%s'", target, want, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestModes(t *testing.T) {
+	tests := map[string][]struct {
+		method string
+		path   string
+		want   int
+	}{
+		httpserver.ModeRead: {
+			{"GET", "/existing.txt", 200},
+			{"HEAD", "/", 200},
+			{"PUT", "/new.txt", 405},
+			{"POST", "/existing.txt", 405},
+			{"DELETE", "/existing.txt", 405},
+		},
+		httpserver.ModeDropbox: {
+			{"GET", "/existing.txt", 405},
+			{"GET", "/", 405},
+			{"GET", "/?format=json", 405},
+			{"OPTIONS", "/existing.txt", 204},
+			{"PUT", "/new.txt", 201},
+			{"PUT", "/new.txt", 409},
+			{"PUT", "/existing.txt", 409},
+		},
+		httpserver.ModeWrite: {
+			{"GET", "/existing.txt", 405},
+			{"HEAD", "/", 405},
+			{"PUT", "/new.txt", 201},
+			{"PUT", "/new.txt", 201},
+			{"DELETE", "/new.txt", 405},
+		},
+		httpserver.ModeReadWrite: {
+			{"PUT", "/new.txt", 201},
+			{"PUT", "/new.txt", 201},
+			{"GET", "/new.txt", 200},
+			{"POST", "/new.txt", 405},
+		},
+	}
+	for mode, requests := range tests {
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"existing.txt": "existing"})
+		// the mode takes precedence over the upload option
+		server, err := httpserver.New(&httpserver.Options{Folder: root, Mode: mode, EnableUpload: mode == httpserver.ModeRead})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, request := range requests {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(request.method, request.path, strings.NewReader("uploaded")))
+			if w.Code != request.want {
+				t.Errorf("%s: %s %s: want %d, got %d", mode, request.method, request.path, request.want, w.Code)
+			}
+			if w.Code == 204 && w.Body.Len() > 0 {
+				t.Errorf("%s: %s %s: want no content, got '%s'", mode, request.method, request.path, w.Body.String())
+			}
+		}
+		if content, _ := os.ReadFile(filepath.Join(root, "existing.txt")); string(content) != "existing" {
+			t.Errorf("%s: existing file overwritten with '%s'", mode, content)
+		}
+	}
+}
+
+func TestDropboxNeverOverwrites(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{".env": "secret"})
+	// the sandbox hides the dotfiles from the served folder, not from the uploads
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Mode: httpserver.ModeDropbox, Sandbox: true, MaxFileSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := put(server, "/.env", "overwritten"); w.Code != 409 {
+		t.Errorf("hidden: want 409, got %d", w.Code)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, ".env")); string(content) != "secret" {
+		t.Errorf("hidden: existing file overwritten with '%s'", content)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mux     sync.Mutex
+		created int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := put(server, "/concurrent.txt", "uploaded"); w.Code == 201 {
+				mux.Lock()
+				created++
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if created != 1 {
+		t.Errorf("concurrent: want a single upload, got %d", created)
+	}
+}
+
+func TestUploadOptionPerServer(t *testing.T) {
+	root := t.TempDir()
+	upload, err := httpserver.New(&httpserver.Options{Folder: root, Mode: httpserver.ModeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a read-only server created later doesn't disable the uploads of the first one
+	readOnly, err := httpserver.New(&httpserver.Options{Folder: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := put(upload, "/uploaded.txt", "uploaded"); w.Code != 201 {
+		t.Errorf("upload: want 201, got %d", w.Code)
+	}
+	put(readOnly, "/ignored.txt", "ignored")
+	if _, err := os.Stat(filepath.Join(root, "ignored.txt")); !os.IsNotExist(err) {
+		t.Errorf("read-only: want no upload, got %v", err)
+	}
+}
+
+func TestDropboxUploadConflict(t *testing.T) {
+	for _, conflict := range []string{httpserver.ConflictOverwrite, httpserver.ConflictVersion} {
+		if _, err := httpserver.New(&httpserver.Options{Folder: t.TempDir(), Mode: httpserver.ModeDropbox, UploadConflict: conflict}); err == nil {
+			t.Errorf("%s: want an error in dropbox mode", conflict)
+		}
+	}
+
+	// renaming keeps the existing file without revealing it
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"report.txt": "existing"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, Mode: httpserver.ModeDropbox, UploadConflict: httpserver.ConflictRename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := put(server, "/report.txt", "uploaded"); w.Code != 201 || w.Header().Get("Location") != "/report%20%281%29.txt" {
+		t.Errorf("rename: want 201 stored as 'report (1).txt', got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "report.txt")); string(content) != "existing" {
+		t.Errorf("rename: existing file overwritten with '%s'", content)
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestMounts(t *testing.T) {
+	docs, builds := t.TempDir(), t.TempDir()
+	writeFiles(t, docs, map[string]string{"index.txt": "docs"})
+	writeFiles(t, builds, map[string]string{"latest.txt": "builds"})
+	server, err := httpserver.New(&httpserver.Options{MaxFileSize: 1, Mounts: []httpserver.Mount{
+		{Prefix: "/docs", Folder: docs},
+		{Prefix: "/ci/builds/", Folder: builds, Upload: true, Sandbox: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"/docs/index.txt":         "docs",
+		"/ci/builds/latest.txt":   "builds",
+		"/":                       `<a href="ci/">ci/</a>`,
+		"/ci/":                    `<a href="builds/">builds/</a>`,
+		"/docs/":                  `<a href="index.txt">index.txt</a>`,
+		"/?format=json":           `"path": "/docs/"`,
+		"/ci/builds/?format=json": `"path": "/ci/builds/latest.txt"`,
+	} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != 200 || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: want '%s', got %d '%s'", path, want, w.Code, w.Body.String())
+		}
+	}
+	// without a folder only the mount points are served
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/mount_test.go", nil))
+	if w.Code != 404 {
+		t.Errorf("want 404 outside of the mounts, got %d", w.Code)
+	}
+
+	for path, want := range map[string]int{"/docs/new.txt": 403, "/ci/builds/new.txt": 201} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("PUT", path, strings.NewReader("uploaded")))
+		if w.Code != want {
+			t.Errorf("%s: want %d, got %d", path, want, w.Code)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(builds, "new.txt")); err != nil || string(content) != "uploaded" {
+		t.Errorf("want the upload in the mounted folder, got '%s' (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(docs, "new.txt")); !os.IsNotExist(err) {
+		t.Error("want no upload in the read-only mount")
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := netsim.NewLimiter(1000)
+	if limiter.Burst() != 1000 {
+		t.Errorf("want a one second burst, got %d", limiter.Burst())
+	}
+	start := time.Now()
+	limiter.WaitN(1000)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("burst: want no wait, got %s", elapsed)
+	}
+	// the bucket is empty, 500 bytes take half a second
+	start = time.Now()
+	limiter.WaitN(500)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Errorf("debt: want about 500ms, got %s", elapsed)
+	}
+}
+
+// flushRecorder records the writes and the flushes of a netsim writer
+type flushRecorder struct {
+	bytes.Buffer
+	flushedAt []int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Len())
+}
+
+func TestWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3000)
+
+	simulator := netsim.New(&netsim.Options{Latency: 200 * time.Millisecond})
+	var buffer bytes.Buffer
+	writer := simulator.NewWriter(&buffer, simulator.NewConnectionLimiter(), -1)
+	start := time.Now()
+	if _, err := writer.Write(data[:10]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write(data[10:]); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("latency: want a single delay of 200ms, got %s", elapsed)
+	}
+	if !bytes.Equal(buffer.Bytes(), data) {
+		t.Errorf("latency: want the data unchanged, got %d bytes", buffer.Len())
+	}
+
+	// 2000 bytes are sent right away, the remaining 1000 within half a second
+	simulator = netsim.New(&netsim.Options{ConnectionRate: 2000})
+	buffer.Reset()
+	writer = simulator.NewWriter(&buffer, simulator.NewConnectionLimiter(), int64(len(data)))
+	start = time.Now()
+	if n, err := writer.Write(data); err != nil || n != len(data) {
+		t.Fatalf("bandwidth: want %d bytes written, got %d %v", len(data), n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Errorf("bandwidth: want about 500ms, got %s", elapsed)
+	}
+	if !bytes.Equal(buffer.Bytes(), data) {
+		t.Errorf("bandwidth: want the data unchanged, got %d bytes", buffer.Len())
+	}
+
+	// the stall happens once, in the middle of the response
+	simulator = netsim.New(&netsim.Options{StallProbability: 1, StallDuration: 200 * time.Millisecond})
+	recorder := &flushRecorder{}
+	writer = simulator.NewWriter(recorder, nil, 100)
+	start = time.Now()
+	if _, err := writer.Write(data[:100]); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > time.Second {
+		t.Errorf("stall: want 200ms, got %s", elapsed)
+	}
+	if len(recorder.flushedAt) != 1 || recorder.flushedAt[0] != 50 || recorder.Len() != 100 {
+		t.Errorf("stall: want the first half flushed before the stall, got %v %d", recorder.flushedAt, recorder.Len())
+	}
+}
+
+func TestDropAndReset(t *testing.T) {
+	count := func(fn func() bool) int {
+		var n int
+		for i := 0; i < 1000; i++ {
+			if fn() {
+				n++
+			}
+		}
+		return n
+	}
+
+	never := netsim.New(&netsim.Options{})
+	if never.Enabled() || count(never.ShouldDrop) != 0 || count(never.ShouldReset) != 0 {
+		t.Errorf("want no drop nor reset without probabilities")
+	}
+	always := netsim.New(&netsim.Options{DropProbability: 1, ResetProbability: 1})
+	if !always.Enabled() || count(always.ShouldDrop) != 1000 || count(always.ShouldReset) != 1000 {
+		t.Errorf("want every connection dropped and reset")
+	}
+	half := netsim.New(&netsim.Options{DropProbability: 0.5, ResetProbability: 0.1})
+	if n := count(half.ShouldDrop); n < 400 || n > 600 {
+		t.Errorf("drop: want about 500 out of 1000, got %d", n)
+	}
+	if n := count(half.ShouldReset); n < 50 || n > 150 {
+		t.Errorf("reset: want about 100 out of 1000, got %d", n)
+	}
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func writeFiles(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	override, base := t.TempDir(), t.TempDir()
+	writeFiles(t, base, map[string]string{"common.txt": "base", "base.txt": "base", "sub/base.txt": "base", "fixtures/data.txt": "base", "only/base.txt": "base", ".secret": "base"})
+	writeFiles(t, override, map[string]string{"common.txt": "override", "sub/override.txt": "override", "fixtures": "file"})
+	server, err := httpserver.New(&httpserver.Options{Folder: override, Overlay: []string{base}, EnableUpload: true, Sandbox: true, MaxFileSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{"/common.txt": "override", "/base.txt": "base", "/sub/base.txt": "base", "/fixtures": "file"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: want '%s', got %d '%s'", path, want, w.Code, w.Body.String())
+		}
+	}
+	// shadowed by the file of the upper layer, and rejected by the sandbox of the lower one
+	for _, path := range []string{"/fixtures/data.txt", "/.secret"} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code == 200 {
+			t.Errorf("%s: want an error, got %d '%s'", path, w.Code, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/sub/?format=json", nil))
+	var result struct {
+		Entries []struct {
+			Name string `json:"name"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || result.Entries[0].Name != "base.txt" || result.Entries[1].Name != "override.txt" {
+		t.Errorf("want the merged folder, got %+v", result.Entries)
+	}
+
+	// uploads go to the first layer, creating the folders existing in the lower ones
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("PUT", "/only/deep.txt", strings.NewReader("uploaded")))
+	if w.Code != 201 {
+		t.Fatalf("want 201, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("PUT", "/base.txt", strings.NewReader("uploaded")))
+	if w.Code != 201 {
+		t.Fatalf("want 201, got %d", w.Code)
+	}
+	for root, name := range map[string]string{override: "only/deep.txt", base: "base.txt"} {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "uploaded"
+		if root == base {
+			want = "base"
+		}
+		if string(content) != want {
+			t.Errorf("%s: want '%s', got '%s'", name, want, content)
+		}
+	}
+}
+
+func TestOverlayUploadPath(t *testing.T) {
+	if _, err := httpserver.New(&httpserver.Options{Folder: t.TempDir(), Overlay: []string{t.TempDir()}, UploadFolder: t.TempDir()}); err == nil {
+		t.Fatal("want an error for an upload path which is not overlaid")
+	}
+}
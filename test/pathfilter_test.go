@@ -0,0 +1,101 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestPathFilter(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"index.txt":                 "public",
+		"server.key":                "key",
+		"node_modules/lib/index.js": "lib",
+		".git/config":               "git",
+		".well-known/security.txt":  "security",
+		"docs/private/notes.txt":    "notes",
+		"docs/public/notes.txt":     "notes",
+		"docs/public/important.key": "key",
+	})
+	ignoreFile := filepath.Join(t.TempDir(), ".gitignore")
+	if err := os.WriteFile(ignoreFile, []byte("# secrets\n*.key\nnode_modules/\n/docs/private\n!docs/public/important.key\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	include, exclude, err := httpserver.LoadIgnoreFile(ignoreFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := httpserver.New(&httpserver.Options{
+		Folder:       root,
+		Sandbox:      true,
+		EnableUpload: true,
+		MaxFileSize:  1,
+		Include:      append(include, ".well-known"),
+		Exclude:      append(exclude, ".git"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]int{
+		"/index.txt":                 200,
+		"/.well-known/security.txt":  200,
+		"/docs/public/notes.txt":     200,
+		"/docs/public/important.key": 200,
+		"/server.key":                404,
+		"/node_modules/lib/index.js": 404,
+		"/docs/private/notes.txt":    404,
+	} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: want %d, got %d", path, want, w.Code)
+		}
+	}
+	// dotfiles which are not included are still rejected by the sandbox
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/.git/config", nil))
+	if w.Code == 200 {
+		t.Errorf("/.git/config: want an error, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/?format=json&depth=3", nil))
+	for _, hidden := range []string{"server.key", "node_modules", ".git", "private"} {
+		if strings.Contains(w.Body.String(), hidden) {
+			t.Errorf("listing: want %s hidden, got %s", hidden, w.Body.String())
+		}
+	}
+	if !strings.Contains(w.Body.String(), "/.well-known/security.txt") {
+		t.Errorf("listing: want .well-known, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/docs/?download=zip", nil))
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if strings.Contains(strings.Join(names, ","), "private") || len(names) != 4 {
+		t.Errorf("archive: want the public folder only, got %v", names)
+	}
+
+	for path, want := range map[string]int{"/uploaded.key": 403, "/node_modules/lib/new.js": 403, "/uploaded.txt": 201} {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("PUT", path, strings.NewReader("uploaded")))
+		if w.Code != want {
+			t.Errorf("PUT %s: want %d, got %d", path, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+	"github.com/projectdiscovery/simplehttpserver/pkg/netsim"
+)
+
+// newUpstream answers with its name, the requested uri and the received headers
+func newUpstream(t *testing.T, name string) *url.URL {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s host=%s forwarded=%s/%s custom=%s cookie=%s", name, r.URL.RequestURI(), r.Host,
+			r.Header.Get("X-Forwarded-Proto"), r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Custom"), r.Header.Get("Cookie"))
+	}))
+	t.Cleanup(upstream.Close)
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestProxyLongestPrefix(t *testing.T) {
+	api, v2 := newUpstream(t, "api"), newUpstream(t, "v2")
+	server, err := httpserver.New(&httpserver.Options{
+		Folder: t.TempDir(),
+		Proxies: []httpserver.ProxyRule{
+			{Prefix: "/api", Upstream: api},
+			{Prefix: "/api/v2", Upstream: v2},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"/api/users":      "api /api/users",
+		"/api":            "api /api",
+		"/api/v2/users":   "v2 /api/v2/users",
+		"/api/v2":         "v2 /api/v2",
+		"/api/v2x/users":  "api /api/v2x/users",
+		"/api/v1?q=a%20b": "api /api/v1?q=a%20b",
+	} {
+		w := serve(server, "GET", path, nil)
+		if !strings.HasPrefix(w.Body.String(), want+" ") {
+			t.Errorf("%s: want '%s', got '%s'", path, want, w.Body.String())
+		}
+	}
+	// the prefix matches whole path segments only
+	if w := serve(server, "GET", "/apifoo", nil); w.Code != 404 {
+		t.Errorf("/apifoo: want 404 from the file handler, got %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestProxyStripPrefixAndHeaders(t *testing.T) {
+	upstream := newUpstream(t, "upstream")
+	upstream.Path = "/base"
+	server, err := httpserver.New(&httpserver.Options{
+		Folder:           t.TempDir(),
+		Proxies:          []httpserver.ProxyRule{{Prefix: "/api", Upstream: upstream}},
+		ProxyStripPrefix: true,
+		ProxyHeaders: []httpserver.HTTPHeader{
+			{Name: "X-Custom", Value: "injected"},
+			{Name: "Cookie", Value: ""},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{"/api/users": "upstream /base/users ", "/api": "upstream /base/ "} {
+		w := serve(server, "GET", path, map[string]string{"Cookie": "session=secret"})
+		body := w.Body.String()
+		if !strings.HasPrefix(body, want) {
+			t.Errorf("%s: want '%s', got '%s'", path, want, body)
+		}
+		if !strings.Contains(body, "host="+upstream.Host) || !strings.Contains(body, "forwarded=http/example.com") {
+			t.Errorf("%s: want the upstream host and the forwarded headers, got '%s'", path, body)
+		}
+		if !strings.Contains(body, "custom=injected") || !strings.HasSuffix(body, "cookie=") {
+			t.Errorf("%s: want the custom header set and the cookie removed, got '%s'", path, body)
+		}
+	}
+
+	server, err = httpserver.New(&httpserver.Options{
+		Folder:            t.TempDir(),
+		Proxies:           []httpserver.ProxyRule{{Prefix: "/api", Upstream: upstream}},
+		ProxyPreserveHost: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(server, "GET", "/api/users", nil); !strings.Contains(w.Body.String(), "upstream /base/api/users host=example.com ") {
+		t.Errorf("preserve host: want the original host, got '%s'", w.Body.String())
+	}
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	// echoes the frames once upgraded, a websocket library isn't needed for the raw stream
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := httpserver.New(&httpserver.Options{
+		Folder:   t.TempDir(),
+		Proxies:  []httpserver.ProxyRule{{Prefix: "/ws", Upstream: upstreamURL}},
+		Compress: true,
+		NetSim:   netsim.New(&netsim.Options{Latency: time.Millisecond}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	front := httptest.NewServer(server)
+	defer front.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(front.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /ws/echo HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nAccept-Encoding: gzip\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("want an uncompressed 101, got %d %v", response.StatusCode, response.Header)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(reader, echo); err != nil || string(echo) != "ping" {
+		t.Errorf("want the echoed frame, got '%s' %v", echo, err)
+	}
+}
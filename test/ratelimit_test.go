@@ -0,0 +1,45 @@
+package test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/ratelimit"
+)
+
+func TestRateLimiterBurstAndRetryAfter(t *testing.T) {
+	limiter := ratelimit.New(1, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("ip:127.0.0.1"); !ok {
+			t.Fatalf("request %d should be allowed within the burst", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("ip:127.0.0.1")
+	if ok {
+		t.Fatal("request exceeding the burst should be rejected")
+	}
+	if retryAfter <= 0 {
+		t.Errorf("want positive retry after, got %s", retryAfter)
+	}
+	if ok, _ := limiter.Allow("ip:127.0.0.2"); !ok {
+		t.Error("other clients should not be limited")
+	}
+}
+
+func TestConnLimiterPerIP(t *testing.T) {
+	limiter := ratelimit.NewConnLimiter(3, 1)
+	first := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1000}
+	second := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1001}
+
+	if !limiter.Acquire(first) {
+		t.Fatal("first connection should be accepted")
+	}
+	if limiter.Acquire(second) {
+		t.Fatal("second connection from the same ip should be rejected")
+	}
+	limiter.Release(first)
+	if !limiter.Acquire(second) {
+		t.Fatal("connection should be accepted once a slot is released")
+	}
+}
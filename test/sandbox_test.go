@@ -0,0 +1,131 @@
+package test
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+const secret = "secret outside of the sandbox"
+
+// traversalPayloads are paths trying to escape the served folder or reach hidden files
+var traversalPayloads = []string{
+	"../secret.txt",
+	"/../secret.txt",
+	"../../../../../../secret.txt",
+	"sub/../../secret.txt",
+	"sub/../../root/../secret.txt",
+	"..\\secret.txt",
+	"sub\\..\\..\\secret.txt",
+	"%2e%2e/secret.txt",
+	"..%2fsecret.txt",
+	"secret.txt\x00.html",
+	"link.txt",
+	"dirlink/secret.txt",
+	"sub/link.txt",
+	".hidden",
+	"sub/.hidden",
+	".git/config",
+	"./.hidden",
+	"sub/./../.hidden",
+}
+
+// newSandboxFixture creates a served folder next to a secret, with symlinks pointing to it
+func newSandboxFixture(t testing.TB) string {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeFiles(t, dir, map[string]string{"secret.txt": secret})
+	writeFiles(t, root, map[string]string{"index.txt": "public", "sub/nested.txt": "nested", ".hidden": secret, "sub/.hidden": secret, ".git/config": secret})
+	for link, target := range map[string]string{"link.txt": "../secret.txt", "dirlink": "..", "sub/link.txt": "../../secret.txt"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSandboxTraversal(t *testing.T) {
+	root := newSandboxFixture(t)
+	sbfs, err := httpserver.NewSandboxFileSystem(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sbfs.Close()
+
+	for name, want := range map[string]string{"/index.txt": "public", "sub/nested.txt": "nested", "/sub/../index.txt": "public"} {
+		f, err := sbfs.Open(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		content, _ := io.ReadAll(f)
+		f.Close()
+		if string(content) != want {
+			t.Errorf("%s: want '%s', got '%s'", name, want, content)
+		}
+	}
+	for _, payload := range traversalPayloads {
+		if f, err := sbfs.Open(payload); err == nil {
+			content, _ := io.ReadAll(f)
+			f.Close()
+			t.Errorf("%s: want an error, got '%s'", payload, content)
+		}
+	}
+}
+
+func TestSandboxUploadTraversal(t *testing.T) {
+	root := newSandboxFixture(t)
+	// uploads are confined even without the sandbox
+	for _, sandbox := range []bool{false, true} {
+		server, err := httpserver.New(&httpserver.Options{Folder: root, EnableUpload: true, Sandbox: sandbox, MaxFileSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, payload := range []string{"/dirlink/secret.txt", "/dirlink/new.txt", "/link.txt", "/sub/link.txt"} {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("PUT", payload, strings.NewReader("overwritten")))
+			if w.Code == 201 {
+				t.Errorf("%s (sandbox %v): want an error, got %d", payload, sandbox, w.Code)
+			}
+		}
+		server.Close()
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "..", "secret.txt")); string(content) != secret {
+		t.Errorf("the secret was overwritten with '%s'", content)
+	}
+	if _, err := os.Stat(filepath.Join(root, "..", "new.txt")); !os.IsNotExist(err) {
+		t.Error("a file was created outside of the folder")
+	}
+}
+
+func FuzzSandboxOpen(f *testing.F) {
+	for _, payload := range traversalPayloads {
+		f.Add(payload)
+	}
+	root := newSandboxFixture(f)
+	sbfs, err := httpserver.NewSandboxFileSystem(root)
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer sbfs.Close()
+
+	f.Fuzz(func(t *testing.T, name string) {
+		file, err := sbfs.Open(name)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			return
+		}
+		if content, _ := io.ReadAll(file); string(content) == secret {
+			t.Fatalf("%q: the secret was served", name)
+		}
+	})
+}
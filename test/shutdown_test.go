@@ -0,0 +1,151 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+	"github.com/projectdiscovery/simplehttpserver/pkg/tcpserver"
+)
+
+func TestHTTPShutdownDrainsRequests(t *testing.T) {
+	root := t.TempDir()
+	server, err := httpserver.New(&httpserver.Options{Folder: root, EnableUpload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	// idle keep-alive connections would hold the shutdown until the server drops them
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	// the server is tracked once it answers
+	for {
+		response, err := client.Get("http://" + listener.Addr().String() + "/")
+		if err == nil {
+			_ = response.Body.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the upload is in flight until the body is complete
+	body, writer := io.Pipe()
+	request, err := http.NewRequest("PUT", "http://"+listener.Addr().String()+"/upload.txt", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.ContentLength = int64(len("uploaded"))
+	responses := make(chan *http.Response, 1)
+	go func() {
+		response, err := client.Do(request)
+		if err != nil {
+			t.Error(err)
+		}
+		responses <- response
+	}()
+	if _, err := writer.Write([]byte("upload")); err != nil {
+		t.Fatal(err)
+	}
+	// let the server receive the request
+	time.Sleep(100 * time.Millisecond)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before the request completed: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if _, err := writer.Write([]byte("ed")); err != nil {
+		t.Fatal(err)
+	}
+	_ = writer.Close()
+	if response := <-responses; response == nil || response.StatusCode != 201 {
+		t.Fatalf("want the in-flight upload completed, got %v", response)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("want a graceful shutdown, got %s", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("want the server closed, got %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "upload.txt")); string(content) != "uploaded" {
+		t.Errorf("want 'uploaded', got '%s'", content)
+	}
+	if err := server.Close(); err != nil {
+		t.Errorf("close after shutdown: %s", err)
+	}
+}
+
+func TestTCPShutdownWakesIdleConnections(t *testing.T) {
+	server, err := tcpserver.New(&tcpserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HandleMessageFnc = func(ctx context.Context, question []byte) ([]byte, error) {
+		return question, nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	var conns []net.Conn
+	for i := 0; i < 10; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		answer := make([]byte, 4)
+		if _, err := io.ReadFull(conn, answer); err != nil || string(answer) != "ping" {
+			t.Fatalf("want 'ping', got '%s' %v", answer, err)
+		}
+		conns = append(conns, conn)
+	}
+
+	// the idle connections wait for their next question, the shutdown must not wait for their read timeout
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("want a graceful shutdown, got %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("want the idle connections woken up, shutdown took %s", elapsed)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("want the server stopped, got %s", err)
+	}
+	for _, conn := range conns {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Errorf("want the connection closed by the server, got %v", err)
+		}
+	}
+}
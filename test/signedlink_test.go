@@ -0,0 +1,163 @@
+package test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func newSignedLinkServer(t *testing.T) *httpserver.HTTPServer {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"report.txt":      "report",
+		"app.js":          "plain",
+		"app.js.gz":       "gzipped",
+		"docs/guide.txt":  "guide",
+		"docs/a/deep.txt": "deep",
+	})
+	server, err := httpserver.New(&httpserver.Options{
+		Folder:            root,
+		BasicAuthUsername: "user",
+		BasicAuthPassword: "pass",
+		SignedLinks:       true,
+		Precompressed:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// tokenCookie returns the cookie set by the response, as sent back by the client
+func tokenCookie(w *httptest.ResponseRecorder) string {
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		return ""
+	}
+	return cookies[0].Name + "=" + cookies[0].Value
+}
+
+func TestSignedLink(t *testing.T) {
+	server := newSignedLinkServer(t)
+	if w := serve(server, "GET", "/report.txt", nil); w.Code != 401 {
+		t.Fatalf("want 401 without credentials, got %d", w.Code)
+	}
+
+	link, err := server.SignURL("/report.txt?download=1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the token is exchanged for a cookie and removed from the url
+	w := serve(server, "GET", link, nil)
+	if w.Code != 302 || w.Header().Get("Location") != "/report.txt?download=1" {
+		t.Fatalf("want a redirect without the token, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].Path != "/report.txt" {
+		t.Fatalf("want an http only cookie scoped to the file, got %v", cookies)
+	}
+	cookie := cookies[0].Name + "=" + cookies[0].Value
+	// the token grants access to the signed file only
+	for path, want := range map[string]int{"/report.txt": 200, "/": 401, "/app.js": 401, "/docs/guide.txt": 401} {
+		if w := serve(server, "GET", path, map[string]string{"Cookie": cookie}); w.Code != want {
+			t.Errorf("%s: want %d with the cookie of the file, got %d", path, want, w.Code)
+		}
+	}
+	if w := serve(server, "GET", "/app.js?token="+cookies[0].Value, nil); w.Code != 401 {
+		t.Errorf("token of another file: want 401, got %d", w.Code)
+	}
+
+	// a folder token grants access to its content
+	link, err = server.SignURL("/docs/", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(server, "GET", link, nil)
+	if cookies := w.Result().Cookies(); w.Code != 302 || len(cookies) != 1 || cookies[0].Path != "/docs/" {
+		t.Fatalf("folder: want a redirect with a cookie scoped to the folder, got %d %v", w.Code, cookies)
+	}
+	folderCookie := tokenCookie(w)
+	for path, want := range map[string]int{"/docs/": 200, "/docs/guide.txt": 200, "/docs/a/deep.txt": 200, "/docs/../report.txt": 401, "/report.txt": 401} {
+		if w := serve(server, "GET", path, map[string]string{"Cookie": folderCookie}); w.Code != want {
+			t.Errorf("%s: want %d with the cookie of the folder, got %d", path, want, w.Code)
+		}
+	}
+
+	// the redirect targets the requested file, not its precompressed sibling
+	link, err = server.SignURL("/app.js", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = serve(server, "GET", link, map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != 302 || w.Header().Get("Location") != "/app.js" {
+		t.Errorf("precompressed: want a redirect to /app.js, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	w = serve(server, "GET", "/app.js", map[string]string{"Accept-Encoding": "gzip", "Cookie": tokenCookie(w)})
+	if w.Code != 200 || w.Body.String() != "gzipped" {
+		t.Errorf("precompressed: want the sibling with the cookie, got %d '%s'", w.Code, w.Body.String())
+	}
+}
+
+func TestSignedLinkRejected(t *testing.T) {
+	server := newSignedLinkServer(t)
+	expired, err := server.SignURL("/report.txt", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := server.SignURL("/report.txt", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := u.Query().Get("token")
+	expiry, signature, _ := strings.Cut(token, ".")
+	// another server signs with its own key
+	other, err := newSignedLinkServer(t).SignURL("/report.txt", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, link := range map[string]string{
+		"expired":          expired,
+		"extended expiry":  "/report.txt?token=" + expiry + "0." + signature,
+		"forged signature": "/report.txt?token=" + expiry + "." + strings.Repeat("0", len(signature)),
+		"no signature":     "/report.txt?token=" + expiry,
+		"other key":        other,
+	} {
+		if w := serve(server, "GET", link, nil); w.Code != 401 || len(w.Result().Cookies()) != 0 {
+			t.Errorf("%s: want 401 without cookie, got %d %v", name, w.Code, w.Result().Cookies())
+		}
+	}
+	if w := serve(server, "GET", "/report.txt", map[string]string{"Cookie": "shs_token=" + expiry + "0." + signature}); w.Code != 401 {
+		t.Errorf("tampered cookie: want 401, got %d", w.Code)
+	}
+
+	// signed links don't apply without authentication
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"report.txt": "report"})
+	public, err := httpserver.New(&httpserver.Options{Folder: root, SignedLinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(public, "GET", "/report.txt", nil); w.Code != 200 {
+		t.Errorf("public: want 200, got %d", w.Code)
+	}
+}
+
+func TestSignedLinkRequiresBasicAuth(t *testing.T) {
+	cmd := exec.Command(buildBinary(t), "-signed-link-ttl", "1h", "-dump-config")
+	if output, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(output), "requires basic auth") {
+		t.Errorf("want an error without basic auth, got %v %s", err, output)
+	}
+	if config := dumpConfig(t, nil, "-signed-link-ttl", "1h", "-basic-auth", "user:pass"); config["signed-link-ttl"] != "1h0m0s" {
+		t.Errorf("want the signed links with basic auth, got %v", config["signed-link-ttl"])
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/httpserver"
+)
+
+func TestSPAFallback(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"index.html": "<app>", "assets/app.js": "app", "about.html": "about"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, SPA: true, SPAIndex: "index.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	navigation := map[string]string{"Accept": "text/html,application/xhtml+xml"}
+	for path, want := range map[string]string{
+		"/dashboard":         "<app>",
+		"/users/42/settings": "<app>",
+		"/missing.html":      "<app>",
+		"/users/john.doe":    "<app>",
+		"/v1.2/docs":         "<app>",
+		"/about.html":        "about",
+		"/assets/app.js":     "app",
+	} {
+		w := serve(server, "GET", path, navigation)
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s: want '%s', got %d '%s'", path, want, w.Code, w.Body.String())
+		}
+	}
+
+	// missing assets and non navigation requests keep their 404
+	for path, headers := range map[string]map[string]string{
+		"/assets/missing.js": navigation,
+		"/favicon.ico":       navigation,
+		"/styles/MAIN.CSS":   navigation,
+		"/users/john.doe":    {"Accept": "application/json"},
+		"/dashboard":         {"Accept": "application/json"},
+	} {
+		if w := serve(server, "GET", path, headers); w.Code != 404 {
+			t.Errorf("%s %v: want 404, got %d '%s'", path, headers, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(server, "POST", "/dashboard", navigation); w.Code == 200 && w.Body.String() == "<app>" {
+		t.Errorf("POST: want no fallback, got the index")
+	}
+}
+
+func TestSPASandboxPython(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"index.html": "<app>", "assets/app.js": "app", ".env": "secret"})
+	server, err := httpserver.New(&httpserver.Options{Folder: root, SPA: true, SPAIndex: "index.html", Sandbox: true, Python: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	navigation := map[string]string{"Accept": "text/html"}
+	if w := serve(server, "GET", "/dashboard", navigation); w.Code != 200 || w.Body.String() != "<app>" {
+		t.Errorf("route: want the index, got %d '%s'", w.Code, w.Body.String())
+	}
+	// existing folders keep their python style listing
+	w := serve(server, "GET", "/assets/", navigation)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "Directory listing for /assets/") || !strings.Contains(w.Body.String(), "app.js") {
+		t.Errorf("listing: want the python listing, got %d '%s'", w.Code, w.Body.String())
+	}
+	// the dotfiles rejected by the sandbox never leak through the fallback
+	w = serve(server, "GET", "/.env", navigation)
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("dotfile: want the file hidden, got %d '%s'", w.Code, w.Body.String())
+	}
+	if w := serve(server, "GET", "/", navigation); strings.Contains(w.Body.String(), ".env") {
+		t.Errorf("root listing: want the dotfile hidden, got '%s'", w.Body.String())
+	}
+}
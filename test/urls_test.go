@@ -0,0 +1,117 @@
+package test
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/simplehttpserver/pkg/binder"
+)
+
+// waitOutput waits for the text in the output of the server
+func waitOutput(t *testing.T, server *serverProcess, text string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(server.output.String(), text) {
+		if time.Now().After(deadline) {
+			t.Fatalf("want '%s' in the output, got %s", text, server.output)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// announcedURLs returns the urls printed for each interface of the served listeners
+func announcedURLs(t *testing.T, server *serverProcess) []*url.URL {
+	t.Helper()
+	var urls []*url.URL
+	for _, line := range strings.Split(server.output.String(), "\n") {
+		if !strings.HasPrefix(line, "\thttp://") {
+			continue
+		}
+		rawURL, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Errorf("invalid url '%s': %s", rawURL, err)
+			continue
+		}
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+func TestIPv6ListenURLs(t *testing.T) {
+	if listener, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("ipv6 loopback not available")
+	} else {
+		_ = listener.Close()
+	}
+
+	// the unspecified ipv6 address is dual-stack, one url per interface address
+	server := startServer(t, nil, "-path", t.TempDir(), "-listen", "[::]:0")
+	_, port, _ := net.SplitHostPort(server.listeners[0].Address)
+	waitOutput(t, server, "\thttp://127.0.0.1:"+port+"/")
+	waitOutput(t, server, "\thttp://[::1]:"+port+"/")
+	hosts := make(map[string]bool)
+	for _, u := range announcedURLs(t, server) {
+		ip := net.ParseIP(u.Hostname())
+		switch {
+		case ip == nil:
+			t.Errorf("%s: want an ip address", u)
+		case ip.IsLinkLocalUnicast():
+			t.Errorf("%s: link local addresses need a zone, want them skipped", u)
+		case ip.To4() == nil && !strings.HasPrefix(u.Host, "["):
+			t.Errorf("%s: want the ipv6 address in brackets", u)
+		case u.Port() != port:
+			t.Errorf("%s: want port %s", u, port)
+		}
+		hosts[u.Hostname()] = true
+	}
+	if !hosts["::1"] || !hosts["127.0.0.1"] {
+		t.Errorf("want ipv4 and ipv6 loopback urls, got %v\n%s", hosts, server.output)
+	}
+	if err := server.stop(t); err != nil {
+		t.Errorf("want a clean exit, got %s", err)
+	}
+
+	// a specific address is announced as is
+	server = startServer(t, nil, "-path", t.TempDir(), "-listen", "[::1]:0")
+	waitOutput(t, server, " on http://"+server.listeners[0].Address+"/")
+	if urls := announcedURLs(t, server); len(urls) != 0 {
+		t.Errorf("want no interface urls, got %v", urls)
+	}
+}
+
+func TestCanListenOnIPv6(t *testing.T) {
+	if listener, err := net.Listen("tcp", "[::1]:0"); err != nil {
+		t.Skip("ipv6 loopback not available")
+	} else {
+		_ = listener.Close()
+	}
+
+	for _, host := range []string{"127.0.0.1", "::1", "::"} {
+		busy, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := busy.Addr().String()
+		if binder.CanListenOn(address) {
+			t.Errorf("%s: want false while the port is in use", address)
+		}
+		_ = busy.Close()
+		if !binder.CanListenOn(address) {
+			t.Errorf("%s: want true once the port is free", address)
+		}
+	}
+	// the dual-stack listener also holds the ipv4 port
+	busy, err := net.Listen("tcp", "[::]:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	if address := net.JoinHostPort("0.0.0.0", strconv.Itoa(busy.Addr().(*net.TCPAddr).Port)); binder.CanListenOn(address) {
+		t.Errorf("%s: want false while the dual-stack port is in use", address)
+	}
+}